@@ -0,0 +1,87 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// +kubebuilder:webhook:path=/mutate-route42-thetechnick-ninja-v1alpha1-clusterzone,mutating=true,failurePolicy=fail,groups=route42.thetechnick.ninja,resources=clusterzones,verbs=create;update,versions=v1alpha1,name=mutation-clusterzone.route42.thetechnick.ninja
+
+// +kubebuilder:webhook:verbs=create;update;delete,path=/validate-route42-thetechnick-ninja-v1alpha1-clusterzone,mutating=false,failurePolicy=fail,groups=route42.thetechnick.ninja,resources=clusterzones,versions=v1alpha1,name=validation-clusterzone.route42.thetechnick.ninja
+
+var (
+	clusterZonelog                   = logf.Log.WithName("clusterZone-resource")
+	_              webhook.Defaulter = (*ClusterZone)(nil)
+	_              webhook.Validator = (*ClusterZone)(nil)
+)
+
+func (z *ClusterZone) Default() {
+	clusterZonelog.Info("default", "ClusterZone", z.Name)
+
+	z.Zone.Default()
+}
+
+func (z *ClusterZone) ValidateCreate() error {
+	clusterZonelog.Info("validate create", "ClusterZone", z.Name)
+	return z.validate(nil)
+}
+
+func (z *ClusterZone) ValidateUpdate(old runtime.Object) error {
+	clusterZonelog.Info("validate update", "ClusterZone", z.Name)
+	return z.validate(old.(*ClusterZone))
+}
+
+func (z *ClusterZone) ValidateDelete() error {
+	clusterZonelog.Info("validate delete", "ClusterZone", z.Name)
+	return validateZoneDelete(z, "ClusterZone", z.Zone)
+}
+
+func (z *ClusterZone) validate(old *ClusterZone) error {
+	var allErrs field.ErrorList
+
+	if err := validateName(
+		field.NewPath("metadata").Child("name"), z.Name); err != nil {
+		allErrs = append(allErrs, err)
+	}
+	var oldZone *ZoneConfig
+	if old != nil {
+		oldZone = &old.Zone
+	}
+	// metadata-only updates, like adding the finalizer, must not be blocked
+	// by zones created before stricter validation
+	if z.Zone.changedFrom(oldZone) {
+		allErrs = append(allErrs, z.Zone.validate(field.NewPath("zone"), oldZone)...)
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return reject(z, "ClusterZone", z.Name, allErrs)
+}
+
+func (z *ClusterZone) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	zoneClient = mgr.GetClient()
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(z).
+		Complete()
+}
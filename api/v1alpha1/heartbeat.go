@@ -0,0 +1,109 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+)
+
+const (
+	// AgentLabel marks the Leases agents use as heartbeat.
+	AgentLabel = "route42.thetechnick.ninja/agent"
+	// AgentZonesAnnotation holds the zones served by an agent
+	// as JSON object of zone name to serial.
+	AgentZonesAnnotation = "route42.thetechnick.ninja/zones"
+	// AgentAliasErrorsAnnotation holds the ALIAS records an agent failed to
+	// resolve as JSON object of RecordSet namespace/name to error message.
+	AgentAliasErrorsAnnotation = "route42.thetechnick.ninja/alias-errors"
+)
+
+// AgentZones maps the fully qualified names of the zones served
+// by an agent to their serial.
+type AgentZones map[string]int
+
+// GetAgentZones returns the zones reported in an agent heartbeat Lease.
+func GetAgentZones(lease *coordinationv1.Lease) (AgentZones, error) {
+	zones := AgentZones{}
+	v, ok := lease.Annotations[AgentZonesAnnotation]
+	if !ok {
+		return zones, nil
+	}
+	if err := json.Unmarshal([]byte(v), &zones); err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
+
+// SetAgentZones stores the zones served by an agent in its heartbeat Lease.
+func SetAgentZones(lease *coordinationv1.Lease, zones AgentZones) error {
+	b, err := json.Marshal(zones)
+	if err != nil {
+		return err
+	}
+	if lease.Annotations == nil {
+		lease.Annotations = map[string]string{}
+	}
+	lease.Annotations[AgentZonesAnnotation] = string(b)
+	return nil
+}
+
+// AgentAliasErrors maps the namespace/name of RecordSets to the error
+// resolving their ALIAS target.
+type AgentAliasErrors map[string]string
+
+// GetAgentAliasErrors returns the ALIAS errors reported in an agent heartbeat Lease.
+func GetAgentAliasErrors(lease *coordinationv1.Lease) (AgentAliasErrors, error) {
+	errs := AgentAliasErrors{}
+	v, ok := lease.Annotations[AgentAliasErrorsAnnotation]
+	if !ok {
+		return errs, nil
+	}
+	if err := json.Unmarshal([]byte(v), &errs); err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
+
+// SetAgentAliasErrors stores the ALIAS errors of an agent in its heartbeat Lease.
+// The annotation is removed, if there are no errors.
+func SetAgentAliasErrors(lease *coordinationv1.Lease, errs AgentAliasErrors) error {
+	if len(errs) == 0 {
+		delete(lease.Annotations, AgentAliasErrorsAnnotation)
+		return nil
+	}
+	b, err := json.Marshal(errs)
+	if err != nil {
+		return err
+	}
+	if lease.Annotations == nil {
+		lease.Annotations = map[string]string{}
+	}
+	lease.Annotations[AgentAliasErrorsAnnotation] = string(b)
+	return nil
+}
+
+// IsLeaseExpired returns true if the Lease was not renewed within its duration.
+func IsLeaseExpired(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	duration := time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	return lease.Spec.RenewTime.Add(duration).Before(now)
+}
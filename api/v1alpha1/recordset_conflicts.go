@@ -0,0 +1,210 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Conflict reasons.
+const (
+	// Another RecordSet already holds a CNAME for the name,
+	// or this CNAME would share its name with other records.
+	ConflictReasonCNAME = "CNAMEConflict"
+	// Another RecordSet of the same type uses a different TTL.
+	ConflictReasonTTL = "TTLMismatch"
+	// Another RecordSet contributes to the same RRset,
+	// but their merge policies or merge keys do not allow sharing it.
+	ConflictReasonMergePolicy = "MergePolicyConflict"
+	// The name is already owned by RecordSets in another namespace.
+	ConflictReasonOwner = "OwnerConflict"
+)
+
+// Conflict describes why a RecordSet can not be served.
+type Conflict struct {
+	// RecordSet that lost the conflict.
+	RecordSet types.NamespacedName
+	// With is the RecordSet that won the conflict.
+	With types.NamespacedName
+	// Reason is one of the ConflictReason constants.
+	Reason  string
+	Message string
+}
+
+// FindConflicts checks the given RecordSets against each other and
+// returns the conflicts keyed by the losing RecordSet.
+// The oldest RecordSet always wins a conflict, so adding a RecordSet never
+// takes names away from RecordSets that are already served.
+func FindConflicts(recordSets []RecordSet) map[types.NamespacedName]Conflict {
+	sorted := make([]*RecordSet, len(recordSets))
+	for i := range recordSets {
+		sorted[i] = &recordSets[i]
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return olderThan(sorted[i], sorted[j])
+	})
+
+	conflicts := map[types.NamespacedName]Conflict{}
+	accepted := map[string][]*RecordSet{}
+	for _, recordSet := range sorted {
+		name := normalizeName(recordSet.Record.FQDN())
+
+		var conflicted bool
+		for _, other := range accepted[name] {
+			if c, ok := recordSet.ConflictsWith(other); ok {
+				conflicts[c.RecordSet] = c
+				conflicted = true
+				break
+			}
+		}
+		if !conflicted {
+			accepted[name] = append(accepted[name], recordSet)
+		}
+	}
+	return conflicts
+}
+
+// ConflictsWith checks if this RecordSet can be served alongside other.
+func (r *RecordSet) ConflictsWith(other *RecordSet) (Conflict, bool) {
+	if normalizeName(r.Record.FQDN()) != normalizeName(other.Record.FQDN()) {
+		return Conflict{}, false
+	}
+
+	c := Conflict{
+		RecordSet: types.NamespacedName{Name: r.Name, Namespace: r.Namespace},
+		With:      types.NamespacedName{Name: other.Name, Namespace: other.Namespace},
+	}
+	rTTLs, otherTTLs := r.Record.rrsetTTLs(), other.Record.rrsetTTLs()
+	// RRsets both RecordSets contribute to, in the order of this RecordSet
+	var common []RecordType
+	for _, rrset := range r.Record.GetRRsets() {
+		for _, t := range r.Record.servedTypes(rrset) {
+			if _, ok := otherTTLs[t]; ok {
+				common = append(common, t)
+			}
+		}
+	}
+	sameRRset := len(common) > 0
+	// merge keys are scoped by namespace, so RRsets are never
+	// shared with RecordSets of other namespaces
+	shared := sameRRset && r.Namespace == other.Namespace &&
+		r.Record.IsShared() && other.Record.IsShared() &&
+		r.Record.MergeKey == other.Record.MergeKey
+	mismatch := ttlMismatch(common, rTTLs, otherTTLs)
+	switch {
+	case r.Namespace != other.Namespace:
+		c.Reason = ConflictReasonOwner
+		c.Message = fmt.Sprintf(
+			"%s is owned by RecordSets in namespace %s", r.Record.FQDN(), other.Namespace)
+
+	case r.Record.hasCNAME() || other.Record.hasCNAME():
+		c.Reason = ConflictReasonCNAME
+		c.Message = fmt.Sprintf(
+			"CNAME at %s can not coexist with other records, conflicts with RecordSet %s",
+			r.Record.FQDN(), c.With)
+
+	case sameRRset && !shared:
+		c.Reason = ConflictReasonMergePolicy
+		if other.Record.IsShared() {
+			c.Message = fmt.Sprintf(
+				"%s RRset is shared with merge key %q by RecordSet %s",
+				common[0], other.Record.MergeKey, c.With)
+		} else {
+			c.Message = fmt.Sprintf(
+				"%s RRset is exclusively owned by RecordSet %s", common[0], c.With)
+		}
+
+	case mismatch != "":
+		c.Reason = ConflictReasonTTL
+		c.Message = fmt.Sprintf(
+			"TTL %s differs from TTL %s of %s RRset in RecordSet %s",
+			rTTLs[mismatch], otherTTLs[mismatch], mismatch, c.With)
+
+	default:
+		return Conflict{}, false
+	}
+	return c, true
+}
+
+// rrsetTTLs returns the TTL of every RRset of the record.
+func (r Record) rrsetTTLs() map[RecordType]time.Duration {
+	ttls := map[RecordType]time.Duration{}
+	for _, rrset := range r.GetRRsets() {
+		for _, t := range r.servedTypes(rrset) {
+			ttls[t] = rrset.TTL.Duration
+		}
+	}
+	return ttls
+}
+
+// servedTypes returns the record types an RRset of the record is served as.
+// ALIAS records and a cnameRef at the apex of the referenced zone are
+// flattened into A and AAAA records.
+func (r Record) servedTypes(rrset RRset) []RecordType {
+	if rrset.Alias != nil || rrset.CNameRef != nil && r.IsApex() {
+		return []RecordType{RecordTypeA, RecordTypeAAAA}
+	}
+	return []RecordType{rrset.GetType()}
+}
+
+// hasCNAME returns true if the record is served as CNAME.
+func (r Record) hasCNAME() bool {
+	for _, rrset := range r.GetRRsets() {
+		for _, t := range r.servedTypes(rrset) {
+			if t == RecordTypeCName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ttlMismatch returns the first of the given types with different TTLs.
+func ttlMismatch(types []RecordType, a, b map[RecordType]time.Duration) RecordType {
+	for _, t := range types {
+		if a[t] != b[t] {
+			return t
+		}
+	}
+	return ""
+}
+
+// IsConflicted returns true if the RecordSet has a Conflict condition
+// with status True.
+func (r *RecordSet) IsConflicted() bool {
+	cond, ok := r.Status.GetCondition(RecordSetConflict)
+	return ok && cond.Status == ConditionTrue
+}
+
+func olderThan(a, b *RecordSet) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
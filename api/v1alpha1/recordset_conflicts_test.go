@@ -0,0 +1,206 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// conflictRecordSet creates a RecordSet for www.example.com.,
+// created age minutes after the first RecordSet.
+func conflictRecordSet(key string, age int, config RecordConfig) RecordSet {
+	nn := parseKey(key)
+	return RecordSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nn.Name,
+			Namespace: nn.Namespace,
+			CreationTimestamp: metav1.NewTime(
+				time.Date(2019, 1, 1, 0, age, 0, 0, time.UTC)),
+		},
+		Record: Record{
+			DNSName:      "www.example.com.",
+			TTL:          metav1.Duration{Duration: time.Minute},
+			RecordConfig: config,
+		},
+	}
+}
+
+// parseKey parses namespace/name keys, the namespace defaults to default.
+func parseKey(key string) types.NamespacedName {
+	if i := strings.Index(key, "/"); i >= 0 {
+		return types.NamespacedName{Namespace: key[:i], Name: key[i+1:]}
+	}
+	return types.NamespacedName{Namespace: "default", Name: key}
+}
+
+func shared(r RecordSet, key string) RecordSet {
+	r.Record.MergePolicy = MergePolicyShared
+	r.Record.MergeKey = key
+	return r
+}
+
+func withTTL(r RecordSet, ttl time.Duration) RecordSet {
+	r.Record.TTL.Duration = ttl
+	return r
+}
+
+func TestFindConflicts(t *testing.T) {
+	a := RecordConfig{A: []string{"192.0.2.1"}}
+	b := RecordConfig{A: []string{"192.0.2.2"}}
+	txt := RecordConfig{TXT: []string{"v=spf1 -all"}}
+	cname := RecordConfig{CName: strPtr("lb.example.net.")}
+
+	tests := []struct {
+		name       string
+		recordSets []RecordSet
+		// expected conflicts, by losing RecordSet and reason
+		conflicts map[string]string
+	}{
+		{
+			name: "exclusive RRset, oldest wins",
+			recordSets: []RecordSet{
+				conflictRecordSet("new", 2, b),
+				conflictRecordSet("old", 1, a),
+			},
+			conflicts: map[string]string{"new": ConflictReasonMergePolicy},
+		},
+		{
+			name: "different types",
+			recordSets: []RecordSet{
+				conflictRecordSet("a", 1, a),
+				conflictRecordSet("txt", 2, txt),
+			},
+		},
+		{
+			name: "CNAME with other records",
+			recordSets: []RecordSet{
+				conflictRecordSet("txt", 1, txt),
+				conflictRecordSet("cname", 2, cname),
+			},
+			conflicts: map[string]string{"cname": ConflictReasonCNAME},
+		},
+		{
+			name: "shared RRset",
+			recordSets: []RecordSet{
+				shared(conflictRecordSet("a", 1, a), "web"),
+				shared(conflictRecordSet("b", 2, b), "web"),
+			},
+		},
+		{
+			name: "shared RRset, different merge key",
+			recordSets: []RecordSet{
+				shared(conflictRecordSet("a", 1, a), "web"),
+				shared(conflictRecordSet("b", 2, b), "mail"),
+			},
+			conflicts: map[string]string{"b": ConflictReasonMergePolicy},
+		},
+		{
+			name: "shared RRset, exclusive contributor",
+			recordSets: []RecordSet{
+				shared(conflictRecordSet("a", 1, a), "web"),
+				conflictRecordSet("b", 2, b),
+			},
+			conflicts: map[string]string{"b": ConflictReasonMergePolicy},
+		},
+		{
+			name: "shared RRset, TTL mismatch",
+			recordSets: []RecordSet{
+				shared(conflictRecordSet("a", 1, a), "web"),
+				withTTL(shared(conflictRecordSet("b", 2, b), "web"), time.Hour),
+			},
+			conflicts: map[string]string{"b": ConflictReasonTTL},
+		},
+		{
+			name: "other namespace",
+			recordSets: []RecordSet{
+				conflictRecordSet("tenant-a/a", 1, a),
+				conflictRecordSet("tenant-b/txt", 2, txt),
+			},
+			conflicts: map[string]string{"tenant-b/txt": ConflictReasonOwner},
+		},
+		{
+			name: "shared RRset, other namespace",
+			recordSets: []RecordSet{
+				shared(conflictRecordSet("tenant-b/b", 2, b), "web"),
+				shared(conflictRecordSet("tenant-a/a", 1, a), "web"),
+			},
+			conflicts: map[string]string{"tenant-b/b": ConflictReasonOwner},
+		},
+		{
+			name: "same age, ordered by namespace and name",
+			recordSets: []RecordSet{
+				conflictRecordSet("tenant-b/a", 1, a),
+				conflictRecordSet("tenant-a/b", 1, b),
+				conflictRecordSet("tenant-a/a", 1, a),
+			},
+			conflicts: map[string]string{
+				"tenant-a/b": ConflictReasonMergePolicy,
+				"tenant-b/a": ConflictReasonOwner,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conflicts := FindConflicts(test.recordSets)
+			if len(conflicts) != len(test.conflicts) {
+				t.Errorf("got %d conflicts, want %d: %v",
+					len(conflicts), len(test.conflicts), conflicts)
+			}
+			for key, reason := range test.conflicts {
+				c, ok := conflicts[parseKey(key)]
+				if !ok {
+					t.Errorf("expected %s to be conflicted", key)
+					continue
+				}
+				if c.Reason != reason {
+					t.Errorf("%s: got reason %s, want %s: %s", key, c.Reason, reason, c.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestConflictsWith(t *testing.T) {
+	r := conflictRecordSet("a", 1, RecordConfig{A: []string{"192.0.2.1"}})
+	other := conflictRecordSet("b", 2, RecordConfig{A: []string{"192.0.2.2"}})
+	other.Record.DNSName = "WWW.Example.com"
+	if _, ok := r.ConflictsWith(&other); !ok {
+		t.Error("expected names to be compared case insensitive")
+	}
+
+	other.Record.DNSName = "mail.example.com."
+	if c, ok := r.ConflictsWith(&other); ok {
+		t.Errorf("unexpected conflict with another name: %s", c.Message)
+	}
+
+	other.Record.DNSName = "www.example.com."
+	c, ok := other.ConflictsWith(&r)
+	if !ok {
+		t.Fatal("expected a conflict")
+	}
+	if want := (types.NamespacedName{Name: "b", Namespace: "default"}); c.RecordSet != want {
+		t.Errorf("got RecordSet %s, want %s", c.RecordSet, want)
+	}
+	if want := (types.NamespacedName{Name: "a", Namespace: "default"}); c.With != want {
+		t.Errorf("got With %s, want %s", c.With, want)
+	}
+}
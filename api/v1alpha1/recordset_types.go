@@ -18,12 +18,16 @@ package v1alpha1
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/miekg/dns"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // RecordSet is the Schema for the recordsets API
 // +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:storageversion
 // +kubebuilder:printcolumn:name="DNS Name",type="string",JSONPath=".record.dnsName"
 // +kubebuilder:printcolumn:name="Type",type="string",JSONPath=".record.type"
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
@@ -31,63 +35,269 @@ type RecordSet struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Record Record `json:"record,omitempty"`
+	Record Record          `json:"record,omitempty"`
+	Status RecordSetStatus `json:"status,omitempty"`
+}
+
+// RecordSetStatus represents the observed state of a RecordSet.
+type RecordSetStatus struct {
+	// Current service state of the RecordSet.
+	Conditions []RecordSetCondition `json:"conditions,omitempty"`
+}
+
+// RecordSetCondition contains details for the current condition of this RecordSet.
+type RecordSetCondition struct {
+	// Type is the type of the condition.
+	Type RecordSetConditionType `json:"type"`
+	// Status of the condition, one of True, False, Unknown.
+	Status ConditionStatus `json:"status"`
+	// Last time the condition transitioned from one status to another.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Unique, one-word, CamelCase reason for the condition's last transition.
+	Reason string `json:"reason,omitempty"`
+	// Human-readable message indicating details about last transition.
+	Message string `json:"message,omitempty"`
+}
+
+// RecordSetConditionType represents a RecordSet condition value.
+type RecordSetConditionType string
+
+const (
+	// RecordSetConflict is True when the RecordSet conflicts with
+	// another RecordSet and is not served.
+	RecordSetConflict RecordSetConditionType = "Conflict"
+	// RecordSetAliasResolved is False when agents failed to resolve
+	// the target of an ALIAS record.
+	RecordSetAliasResolved RecordSetConditionType = "AliasResolved"
+)
+
+// ConditionStatus represents a condition's status.
+type ConditionStatus string
+
+// ConditionStatus values.
+const (
+	ConditionTrue    ConditionStatus = "True"
+	ConditionFalse   ConditionStatus = "False"
+	ConditionUnknown ConditionStatus = "Unknown"
+)
+
+// GetCondition returns the condition with the given type.
+func (s *RecordSetStatus) GetCondition(t RecordSetConditionType) (RecordSetCondition, bool) {
+	for _, cond := range s.Conditions {
+		if cond.Type == t {
+			return cond, true
+		}
+	}
+	return RecordSetCondition{}, false
+}
+
+// SetCondition adds or updates the given condition.
+// LastTransitionTime is only bumped when the status changes.
+// Returns true if the condition changed.
+func (s *RecordSetStatus) SetCondition(cond RecordSetCondition) bool {
+	for i, existing := range s.Conditions {
+		if existing.Type != cond.Type {
+			continue
+		}
+		if existing.Status == cond.Status &&
+			existing.Reason == cond.Reason &&
+			existing.Message == cond.Message {
+			return false
+		}
+		if existing.Status == cond.Status {
+			cond.LastTransitionTime = existing.LastTransitionTime
+		} else {
+			cond.LastTransitionTime = metav1.Now()
+		}
+		s.Conditions[i] = cond
+		return true
+	}
+	cond.LastTransitionTime = metav1.Now()
+	s.Conditions = append(s.Conditions, cond)
+	return true
 }
 
 // Record holds the settings for this RecordSet.
 type Record struct {
 	// DNS_NAME that this record belongs to.
-	// must be fully qualified.
+	// must be fully qualified, unless ZoneRef is set.
 	// must belong to a existing Zone object.
 	DNSName string `json:"dnsName"`
+	// ZoneRef is the name of the Zone or ClusterZone DNSName is relative to.
+	// With a ZoneRef, DNSName is a relative name like "www" or "_dmarc",
+	// or "@" for the zone apex.
+	ZoneRef string `json:"zoneRef,omitempty"`
 	// TTL of the DNS entry.
 	TTL          metav1.Duration `json:"ttl"`
 	RecordConfig `json:",inline"`
+	// RRsets holds several typed RRsets for DNSName,
+	// instead of the single record type above.
+	RRsets []RRset `json:"rrsets,omitempty"`
 	// Type of the RecordSet.
 	Type RecordType `json:"type,omitempty"`
+	// MergePolicy controls whether other RecordSets may contribute
+	// values to the same RRset. Defaults to Exclusive.
+	MergePolicy MergePolicy `json:"mergePolicy,omitempty"`
+	// MergeKey must match between all RecordSets sharing an RRset.
+	// Merge keys are scoped by namespace, RecordSets in other namespaces
+	// never share an RRset. Required for the Shared MergePolicy.
+	MergeKey string `json:"mergeKey,omitempty"`
+}
+
+// RRset holds the values of a single record type.
+type RRset struct {
+	// TTL of the RRset, defaults to the TTL of the RecordSet.
+	TTL          *metav1.Duration `json:"ttl,omitempty"`
+	RecordConfig `json:",inline"`
+}
+
+// MergePolicy controls how RecordSets for the same name and type are combined.
+type MergePolicy string
+
+// MergePolicy values.
+const (
+	// The RecordSet is the only source of values for its RRset.
+	MergePolicyExclusive MergePolicy = "Exclusive"
+	// Values of all RecordSets with the same MergeKey are merged into one RRset.
+	MergePolicyShared MergePolicy = "Shared"
+)
+
+// IsShared returns true if the record may be merged with other records.
+func (r Record) IsShared() bool {
+	return r.MergePolicy == MergePolicyShared
 }
 
 // GetType returns the type of the record.
 func (r Record) GetType() RecordType {
+	if len(r.RRsets) > 0 {
+		return RecordTypeMulti
+	}
+	return r.RecordConfig.GetType()
+}
+
+// FQDN returns the fully qualified owner name of the record.
+func (r Record) FQDN() string {
+	return r.OwnerName(r.ZoneRef)
+}
+
+// OwnerName returns the fully qualified owner name of the record
+// in the zone with the given origin.
+// Names of records without a ZoneRef are already fully qualified.
+func (r Record) OwnerName(origin string) string {
+	if r.ZoneRef == "" {
+		return dns.Fqdn(r.DNSName)
+	}
+	return AbsoluteName(r.DNSName, origin)
+}
+
+// AbsoluteName resolves a name relative to the given origin,
+// "@" is the origin itself.
+func AbsoluteName(name, origin string) string {
+	if name == "@" {
+		return dns.Fqdn(origin)
+	}
+	return dns.Fqdn(name + "." + strings.TrimSuffix(origin, "."))
+}
+
+// IsApex returns true if the record is the apex of its referenced zone.
+func (r Record) IsApex() bool {
+	return r.ZoneRef != "" && r.DNSName == "@"
+}
+
+// InZone returns true if the record belongs to the zone,
+// either by its ZoneRef or by being the apex of or below the zone.
+func (r Record) InZone(zone string) bool {
+	zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+	if r.ZoneRef != "" {
+		return strings.ToLower(strings.TrimSuffix(r.ZoneRef, ".")) == zone
+	}
+	name := strings.ToLower(strings.TrimSuffix(r.DNSName, "."))
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}
+
+// HasType returns true if the record holds an RRset of the given type.
+func (r Record) HasType(t RecordType) bool {
+	for _, rrset := range r.GetRRsets() {
+		if rrset.GetType() == t {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRRsets returns all RRsets of the record with their TTL set.
+// A record of a single type is returned as a single RRset.
+func (r Record) GetRRsets() []RRset {
+	if len(r.RRsets) == 0 {
+		ttl := r.TTL
+		return []RRset{{TTL: &ttl, RecordConfig: r.RecordConfig}}
+	}
+
+	rrsets := make([]RRset, len(r.RRsets))
+	for i, rrset := range r.RRsets {
+		rrsets[i] = rrset
+		if rrset.TTL == nil {
+			ttl := r.TTL
+			rrsets[i].TTL = &ttl
+		}
+	}
+	return rrsets
+}
+
+// GetType returns the type of the record values.
+func (c RecordConfig) GetType() RecordType {
 	switch {
-	case len(r.RecordConfig.A) > 0:
+	case len(c.A) > 0:
 		return RecordTypeA
-	case len(r.RecordConfig.AAAA) > 0:
+	case len(c.AAAA) > 0:
 		return RecordTypeAAAA
-	case len(r.RecordConfig.TXT) > 0:
+	case len(c.TXT) > 0:
 		return RecordTypeTXT
-	case r.RecordConfig.CName != nil:
+	case c.CName != nil || c.CNameRef != nil:
 		return RecordTypeCName
-	case len(r.RecordConfig.NS) > 0:
+	case len(c.NS) > 0 || len(c.NSRefs) > 0:
 		return RecordTypeNS
-	case len(r.RecordConfig.MX) > 0:
+	case len(c.MX) > 0:
 		return RecordTypeMX
-	case len(r.RecordConfig.SRV) > 0:
+	case len(c.SRV) > 0:
 		return RecordTypeSRV
+	case c.Alias != nil:
+		return RecordTypeAlias
 	default:
 		return RecordTypeUnknown
 	}
 }
 
-func (r Record) Values() []string {
+// Values returns the record values in RFC 1035 presentation format.
+// Values referencing a target and ALIAS records are not included,
+// until they are resolved.
+func (c RecordConfig) Values() []string {
 	var values []string
-	switch r.GetType() {
+	switch c.GetType() {
 	case RecordTypeA:
-		values = r.A
+		values = c.A
 	case RecordTypeAAAA:
-		values = r.AAAA
+		values = c.AAAA
 	case RecordTypeTXT:
-		values = r.TXT
+		values = c.TXT
 	case RecordTypeCName:
-		values = []string{*r.CName}
+		if c.CName != nil {
+			values = []string{*c.CName}
+		}
 	case RecordTypeNS:
-		values = r.NS
+		values = c.NS
 	case RecordTypeMX:
-		for _, mx := range r.MX {
+		for _, mx := range c.MX {
+			if mx.Host == "" {
+				continue
+			}
 			values = append(values, fmt.Sprintf("%d %s", mx.Priority, mx.Host))
 		}
 	case RecordTypeSRV:
-		for _, srv := range r.SRV {
+		for _, srv := range c.SRV {
+			if srv.Host == "" {
+				continue
+			}
 			values = append(values, fmt.Sprintf(
 				"%d %d %d %s", srv.Priority, srv.Weight, srv.Port, srv.Host))
 		}
@@ -95,6 +305,59 @@ func (r Record) Values() []string {
 	return values
 }
 
+// maxTXTSegment is the longest character-string of a TXT record.
+const maxTXTSegment = 255
+
+// TXTSegments splits a TXT value into the character-strings of its record,
+// each at most 255 bytes long.
+func TXTSegments(value string) []string {
+	segments := []string{}
+	for len(value) > maxTXTSegment {
+		segments = append(segments, value[:maxTXTSegment])
+		value = value[maxTXTSegment:]
+	}
+	return append(segments, value)
+}
+
+// TargetRefs returns all target references of the record values.
+func (c RecordConfig) TargetRefs() []TargetRef {
+	var refs []TargetRef
+	if c.CNameRef != nil {
+		refs = append(refs, *c.CNameRef)
+	}
+	refs = append(refs, c.NSRefs...)
+	for _, mx := range c.MX {
+		if mx.HostRef != nil {
+			refs = append(refs, *mx.HostRef)
+		}
+	}
+	for _, srv := range c.SRV {
+		if srv.HostRef != nil {
+			refs = append(refs, *srv.HostRef)
+		}
+	}
+	return refs
+}
+
+// HasTargetRefs returns true if any value references a target,
+// or the record is an ALIAS.
+func (c RecordConfig) HasTargetRefs() bool {
+	if c.CNameRef != nil || len(c.NSRefs) > 0 || c.Alias != nil {
+		return true
+	}
+	for _, mx := range c.MX {
+		if mx.HostRef != nil {
+			return true
+		}
+	}
+	for _, srv := range c.SRV {
+		if srv.HostRef != nil {
+			return true
+		}
+	}
+	return false
+}
+
 // RecordType represents the DNS record type.
 type RecordType string
 
@@ -108,6 +371,11 @@ const (
 	RecordTypeNS      RecordType = "NS"
 	RecordTypeMX      RecordType = "MX"
 	RecordTypeSRV     RecordType = "SRV"
+	// RecordTypeAlias is served as A and AAAA records,
+	// with the addresses of its target.
+	RecordTypeAlias RecordType = "ALIAS"
+	// RecordTypeMulti is the type of records holding several RRsets.
+	RecordTypeMulti RecordType = "Multi"
 )
 
 // RecordConfig holds values for a record type.
@@ -117,21 +385,36 @@ type RecordConfig struct {
 	// AAAA record, list of IPv6 addresses.
 	AAAA []string `json:"aaaa,omitempty"`
 	// TXT record, list of strings.
+	// Values are taken literally, quotes and backslashes are escaped when served.
+	// Strings longer than 255 bytes are split into multiple segments
+	// of the same record.
 	TXT []string `json:"txt,omitempty"`
 	// CNAME record, Canonical Name of DNSName.
 	CName *string `json:"cname,omitempty"`
+	// CNAME record, pointing to the hostname of the referenced target.
+	// At the zone apex, or for targets without hostname, the addresses
+	// of the target are served as A and AAAA records instead.
+	CNameRef *TargetRef `json:"cnameRef,omitempty"`
 	// NS record, list of domain names.
 	NS []string `json:"ns,omitempty"`
+	// NS record, list of referenced targets.
+	NSRefs []TargetRef `json:"nsRefs,omitempty"`
 	// MX record, list of MX records.
 	MX []MX `json:"mx,omitempty"`
 	// SRV record, list of SRV records.
 	SRV []SRV `json:"srv,omitempty"`
+	// ALIAS record, hostname that is periodically resolved by the agent.
+	// Its addresses are served as A and AAAA records, so unlike a CNAME,
+	// an ALIAS can be used at the zone apex.
+	Alias *string `json:"alias,omitempty"`
 }
 
 // MX mail server record.
 type MX struct {
 	Priority int    `json:"priority"`
-	Host     string `json:"host"`
+	Host     string `json:"host,omitempty"`
+	// HostRef references the target of the record instead of Host.
+	HostRef *TargetRef `json:"hostRef,omitempty"`
 }
 
 // SRV record.
@@ -139,9 +422,55 @@ type SRV struct {
 	Priority int    `json:"priority"`
 	Weight   int    `json:"weight"`
 	Port     int    `json:"port"`
-	Host     string `json:"host"`
+	Host     string `json:"host,omitempty"`
+	// HostRef references the target of the record instead of Host.
+	HostRef *TargetRef `json:"hostRef,omitempty"`
+}
+
+// TargetRef references a RecordSet or Service, which is resolved
+// to its live hostname or addresses by the agent.
+type TargetRef struct {
+	// Kind of the target, RecordSet or Service.
+	Kind TargetKind `json:"kind"`
+	// Name of the target.
+	Name string `json:"name"`
+	// Namespace of the target, defaults to the namespace of the RecordSet.
+	// Targets in other namespaces must allow references from the namespace
+	// of the RecordSet with the allow-references-from annotation.
+	Namespace string `json:"namespace,omitempty"`
 }
 
+// AllowReferencesAnnotation lists the namespaces RecordSets may reference
+// a RecordSet or Service from, separated by commas, or "*" for all.
+// Targets are always referenceable from their own namespace.
+const AllowReferencesAnnotation = "route42.thetechnick.ninja/allow-references-from"
+
+// ReferenceAllowed returns true if a RecordSet in namespace
+// may reference the given target.
+func ReferenceAllowed(target metav1.Object, namespace string) bool {
+	if target.GetNamespace() == namespace {
+		return true
+	}
+	for _, allowed := range strings.Split(target.GetAnnotations()[AllowReferencesAnnotation], ",") {
+		if allowed = strings.TrimSpace(allowed); allowed == "*" || allowed == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// TargetKind is the kind of object referenced by a TargetRef.
+type TargetKind string
+
+// TargetKind values.
+const (
+	// The owner name of the RecordSet, or its A and AAAA records.
+	TargetKindRecordSet TargetKind = "RecordSet"
+	// The external name or load balancer hostname of the Service,
+	// or its load balancer, external or cluster IPs.
+	TargetKindService TargetKind = "Service"
+)
+
 // RecordSetList contains a list of RecordSet
 // +kubebuilder:object:root=true
 type RecordSetList struct {
@@ -17,14 +17,21 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"context"
+	"fmt"
+	"math"
 	"net"
+	"reflect"
+	"strings"
+	"time"
 
+	"github.com/miekg/dns"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	runtime "k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
@@ -37,6 +44,19 @@ var (
 	recordSetlog                   = logf.Log.WithName("recordSet-resource")
 	_            webhook.Defaulter = (*Zone)(nil)
 	_            webhook.Validator = (*Zone)(nil)
+
+	// recordSetClient is used to check new RecordSets against existing ones.
+	recordSetClient client.Reader
+)
+
+const (
+	// minTTL is the lowest TTL accepted for records.
+	minTTL = time.Second
+	// maxTTL is the highest TTL accepted for records, see RFC 2181 section 8.
+	maxTTL = math.MaxInt32 * time.Second
+	// maxTXTLength is the longest TXT value accepted, which still fits into
+	// the RDATA of a single record after splitting into 255 byte segments.
+	maxTXTLength = 255 * maxTXTSegment
 )
 
 func (r *RecordSet) Default() {
@@ -44,6 +64,38 @@ func (r *RecordSet) Default() {
 		types.NamespacedName{Name: r.Name, Namespace: r.Namespace})
 
 	r.Record.Type = r.Record.GetType()
+	if r.Record.MergePolicy == "" {
+		r.Record.MergePolicy = MergePolicyExclusive
+	}
+	r.Record.RecordConfig.defaultTargets()
+	for i := range r.Record.RRsets {
+		r.Record.RRsets[i].RecordConfig.defaultTargets()
+	}
+}
+
+// defaultTargets makes the targets of CNAME, NS, MX, SRV and ALIAS records
+// fully qualified, as relative targets have always been served that way.
+func (c *RecordConfig) defaultTargets() {
+	fqdn := func(name *string) {
+		if *name != "" {
+			*name = dns.Fqdn(*name)
+		}
+	}
+	if c.CName != nil {
+		fqdn(c.CName)
+	}
+	for i := range c.NS {
+		fqdn(&c.NS[i])
+	}
+	for i := range c.MX {
+		fqdn(&c.MX[i].Host)
+	}
+	for i := range c.SRV {
+		fqdn(&c.SRV[i].Host)
+	}
+	if c.Alias != nil {
+		fqdn(c.Alias)
+	}
 }
 
 func (r *RecordSet) ValidateCreate() error {
@@ -67,10 +119,7 @@ func (r *RecordSet) ValidateDelete() error {
 func (r *RecordSet) validate(old *RecordSet) error {
 	var allErrs field.ErrorList
 
-	if err := validateName(
-		field.NewPath("record").Child("dnsName"), r.Record.DNSName); err != nil {
-		allErrs = append(allErrs, err)
-	}
+	allErrs = append(allErrs, validateOwnerName(r.Record)...)
 
 	if r.Record.Type == RecordTypeUnknown || r.Record.Type == "" {
 		path := field.NewPath("record").Child("type")
@@ -78,92 +127,306 @@ func (r *RecordSet) validate(old *RecordSet) error {
 		allErrs = append(allErrs, err)
 	}
 
-	switch r.Record.Type {
+	allErrs = append(allErrs, validateMergePolicy(r.Record)...)
+	allErrs = append(allErrs, validateTTLs(r.Record)...)
+
+	if r.Record.Type == RecordTypeMulti {
+		allErrs = append(allErrs, validateRRsets(r.Record)...)
+	} else {
+		allErrs = append(allErrs, validateRecordConfig(
+			field.NewPath("record"), r.Record.Type, r.Record.RecordConfig)...)
+	}
+
+	// metadata-only updates must not be blocked by existing conflicts
+	if len(allErrs) == 0 &&
+		(old == nil || !reflect.DeepEqual(old.Record, r.Record)) {
+		conflictErrs, err := r.validateConflicts()
+		if err != nil {
+			return apierrors.NewInternalError(err)
+		}
+		allErrs = append(allErrs, conflictErrs...)
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return reject(r, "RecordSet", r.Name, allErrs)
+}
+
+// validateConflicts checks the RecordSet against all existing RecordSets,
+// that are not already in conflict themselves.
+func (r *RecordSet) validateConflicts() (field.ErrorList, error) {
+	if recordSetClient == nil {
+		return nil, nil
+	}
+
+	recordSetList := &RecordSetList{}
+	if err := recordSetClient.List(context.Background(), recordSetList); err != nil {
+		return nil, fmt.Errorf("listing RecordSets: %w", err)
+	}
+
+	var errs field.ErrorList
+	for i := range recordSetList.Items {
+		other := &recordSetList.Items[i]
+		if other.Name == r.Name && other.Namespace == r.Namespace {
+			continue
+		}
+		if other.IsConflicted() {
+			continue
+		}
+		if c, ok := r.ConflictsWith(other); ok {
+			path := field.NewPath("record").Child("dnsName")
+			errs = append(errs, field.Invalid(path, r.Record.DNSName, c.Message))
+		}
+	}
+	return errs, nil
+}
+
+// validateOwnerName checks DNSName, which is relative to ZoneRef if set.
+func validateOwnerName(r Record) []*field.Error {
+	namePath := field.NewPath("record").Child("dnsName")
+	if r.ZoneRef == "" {
+		return filterNil(nil, validateName(namePath, r.DNSName))
+	}
+
+	if err := validateName(
+		field.NewPath("record").Child("zoneRef"), r.ZoneRef); err != nil {
+		return []*field.Error{err}
+	}
+	if dns.IsFqdn(r.DNSName) {
+		return []*field.Error{field.Invalid(namePath, r.DNSName,
+			"must be relative to zoneRef, without a trailing dot")}
+	}
+	if _, ok := dns.IsDomainName(r.FQDN()); r.DNSName == "" || !ok {
+		return []*field.Error{field.Invalid(namePath, r.DNSName,
+			`not a valid name relative to zoneRef, use "@" for the zone apex`)}
+	}
+	return nil
+}
+
+// validateRRsets checks the RRsets of a multi type record.
+// Every RRset must hold exactly one record type, that is unique within
+// the record, and a CNAME can not coexist with other RRsets.
+func validateRRsets(r Record) []*field.Error {
+	recordPath := field.NewPath("record")
+	rrsetsPath := recordPath.Child("rrsets")
+
+	var errs []*field.Error
+	if t := r.RecordConfig.GetType(); t != RecordTypeUnknown {
+		errs = append(errs, field.Invalid(recordPath.Child(strings.ToLower(string(t))),
+			r.RecordConfig.Values(), "can not be combined with rrsets"))
+	}
+
+	seen := map[RecordType]struct{}{}
+	for i, rrset := range r.RRsets {
+		path := rrsetsPath.Index(i)
+		t := rrset.GetType()
+		if t == RecordTypeUnknown {
+			errs = append(errs, field.Required(path, "one record type must be set"))
+			continue
+		}
+		served := r.servedTypes(rrset)
+		if duplicate := seenTypes(seen, served); duplicate != "" {
+			errs = append(errs, field.Duplicate(path, string(duplicate)))
+			continue
+		}
+
+		errs = append(errs, validateRecordConfig(path, t, rrset.RecordConfig)...)
+		if served[0] == RecordTypeCName && len(r.RRsets) > 1 {
+			const msg = "CNAME records can not coexist with other records"
+			if rrset.CName != nil {
+				errs = append(errs, field.Invalid(path.Child("cname"), *rrset.CName, msg))
+			} else {
+				errs = append(errs, field.Invalid(path.Child("cnameRef"), rrset.CNameRef, msg))
+			}
+		}
+	}
+	return errs
+}
+
+// seenTypes records the given types as seen,
+// returning the first type that was already seen.
+func seenTypes(seen map[RecordType]struct{}, types []RecordType) RecordType {
+	for _, t := range types {
+		if _, ok := seen[t]; ok {
+			return t
+		}
+	}
+	for _, t := range types {
+		seen[t] = struct{}{}
+	}
+	return ""
+}
+
+// validateRecordConfig checks the values of the given record type,
+// and that no values of other types are set.
+func validateRecordConfig(
+	fldPath *field.Path, recordType RecordType, c RecordConfig) []*field.Error {
+	var errs []*field.Error
+	switch recordType {
 	case RecordTypeA:
-		allErrs = append(allErrs, filterNil(
-			validateA(r.Record.A),
-			noAAAA(r.Record.AAAA),
-			noTXT(r.Record.TXT),
-			noCName(r.Record.CName),
-			noNS(r.Record.NS),
-			noMX(r.Record.MX),
-			noSRV(r.Record.SRV),
-		)...)
+		errs = filterNil(
+			validateA(fldPath, c.A),
+			noAAAA(fldPath, c.AAAA),
+			noTXT(fldPath, c.TXT),
+			noCName(fldPath, c.CName),
+			noCNameRef(fldPath, c.CNameRef),
+			noNS(fldPath, c.NS),
+			noNSRefs(fldPath, c.NSRefs),
+			noMX(fldPath, c.MX),
+			noSRV(fldPath, c.SRV),
+			noAlias(fldPath, c.Alias),
+		)
 
 	case RecordTypeAAAA:
-		allErrs = append(allErrs, filterNil(
-			validateAAAA(r.Record.AAAA),
-			noA(r.Record.A),
-			noTXT(r.Record.TXT),
-			noCName(r.Record.CName),
-			noNS(r.Record.NS),
-			noMX(r.Record.MX),
-			noSRV(r.Record.SRV),
-		)...)
+		errs = filterNil(
+			validateAAAA(fldPath, c.AAAA),
+			noA(fldPath, c.A),
+			noTXT(fldPath, c.TXT),
+			noCName(fldPath, c.CName),
+			noCNameRef(fldPath, c.CNameRef),
+			noNS(fldPath, c.NS),
+			noNSRefs(fldPath, c.NSRefs),
+			noMX(fldPath, c.MX),
+			noSRV(fldPath, c.SRV),
+			noAlias(fldPath, c.Alias),
+		)
 
 	case RecordTypeTXT:
-		allErrs = append(allErrs, filterNil(
-			nil,
-			noA(r.Record.A),
-			noAAAA(r.Record.AAAA),
-			noCName(r.Record.CName),
-			noNS(r.Record.NS),
-			noMX(r.Record.MX),
-			noSRV(r.Record.SRV),
-		)...)
+		errs = filterNil(
+			validateTXT(fldPath, c.TXT),
+			noA(fldPath, c.A),
+			noAAAA(fldPath, c.AAAA),
+			noCName(fldPath, c.CName),
+			noCNameRef(fldPath, c.CNameRef),
+			noNS(fldPath, c.NS),
+			noNSRefs(fldPath, c.NSRefs),
+			noMX(fldPath, c.MX),
+			noSRV(fldPath, c.SRV),
+			noAlias(fldPath, c.Alias),
+		)
 
 	case RecordTypeCName:
-		allErrs = append(allErrs, filterNil(
-			nil,
-			noA(r.Record.A),
-			noAAAA(r.Record.AAAA),
-			noTXT(r.Record.TXT),
-			noNS(r.Record.NS),
-			noMX(r.Record.MX),
-			noSRV(r.Record.SRV),
-		)...)
+		errs = filterNil(
+			validateCName(fldPath, c),
+			noA(fldPath, c.A),
+			noAAAA(fldPath, c.AAAA),
+			noTXT(fldPath, c.TXT),
+			noNS(fldPath, c.NS),
+			noNSRefs(fldPath, c.NSRefs),
+			noMX(fldPath, c.MX),
+			noSRV(fldPath, c.SRV),
+			noAlias(fldPath, c.Alias),
+		)
 
 	case RecordTypeNS:
-		allErrs = append(allErrs, filterNil(
-			nil,
-			noA(r.Record.A),
-			noAAAA(r.Record.AAAA),
-			noTXT(r.Record.TXT),
-			noCName(r.Record.CName),
-			noMX(r.Record.MX),
-			noSRV(r.Record.SRV),
-		)...)
+		errs = filterNil(
+			append(validateNS(fldPath, c.NS), validateNSRefs(fldPath, c.NSRefs)...),
+			noA(fldPath, c.A),
+			noAAAA(fldPath, c.AAAA),
+			noTXT(fldPath, c.TXT),
+			noCName(fldPath, c.CName),
+			noCNameRef(fldPath, c.CNameRef),
+			noMX(fldPath, c.MX),
+			noSRV(fldPath, c.SRV),
+			noAlias(fldPath, c.Alias),
+		)
 
 	case RecordTypeMX:
-		allErrs = append(allErrs, filterNil(
-			nil,
-			noA(r.Record.A),
-			noAAAA(r.Record.AAAA),
-			noTXT(r.Record.TXT),
-			noCName(r.Record.CName),
-			noNS(r.Record.NS),
-			noSRV(r.Record.SRV),
-		)...)
+		errs = filterNil(
+			validateMX(fldPath, c.MX),
+			noA(fldPath, c.A),
+			noAAAA(fldPath, c.AAAA),
+			noTXT(fldPath, c.TXT),
+			noCName(fldPath, c.CName),
+			noCNameRef(fldPath, c.CNameRef),
+			noNS(fldPath, c.NS),
+			noNSRefs(fldPath, c.NSRefs),
+			noSRV(fldPath, c.SRV),
+			noAlias(fldPath, c.Alias),
+		)
 
 	case RecordTypeSRV:
-		allErrs = append(allErrs, filterNil(
-			nil,
-			noA(r.Record.A),
-			noAAAA(r.Record.AAAA),
-			noTXT(r.Record.TXT),
-			noCName(r.Record.CName),
-			noNS(r.Record.NS),
-			noMX(r.Record.MX),
-		)...)
+		errs = filterNil(
+			validateSRV(fldPath, c.SRV),
+			noA(fldPath, c.A),
+			noAAAA(fldPath, c.AAAA),
+			noTXT(fldPath, c.TXT),
+			noCName(fldPath, c.CName),
+			noCNameRef(fldPath, c.CNameRef),
+			noNS(fldPath, c.NS),
+			noNSRefs(fldPath, c.NSRefs),
+			noMX(fldPath, c.MX),
+			noAlias(fldPath, c.Alias),
+		)
+
+	case RecordTypeAlias:
+		errs = filterNil(
+			validateAlias(fldPath, c.Alias),
+			noA(fldPath, c.A),
+			noAAAA(fldPath, c.AAAA),
+			noTXT(fldPath, c.TXT),
+			noCName(fldPath, c.CName),
+			noCNameRef(fldPath, c.CNameRef),
+			noNS(fldPath, c.NS),
+			noNSRefs(fldPath, c.NSRefs),
+			noMX(fldPath, c.MX),
+			noSRV(fldPath, c.SRV),
+		)
 	}
+	return errs
+}
 
-	if len(allErrs) == 0 {
-		return nil
+// validateTTLs checks the TTL of the record and of its RRsets.
+func validateTTLs(r Record) []*field.Error {
+	recordPath := field.NewPath("record")
+	errs := filterNil(nil, validateTTL(recordPath.Child("ttl"), r.TTL.Duration))
+	for i, rrset := range r.RRsets {
+		if rrset.TTL == nil {
+			continue
+		}
+		errs = filterNil(errs, validateTTL(
+			recordPath.Child("rrsets").Index(i).Child("ttl"), rrset.TTL.Duration))
 	}
+	return errs
+}
 
-	return apierrors.NewInvalid(
-		schema.GroupKind{Group: GroupVersion.Group, Kind: "Zone"},
-		r.Name, allErrs)
+func validateTTL(path *field.Path, ttl time.Duration) *field.Error {
+	if ttl < minTTL || ttl > maxTTL {
+		return field.Invalid(path, ttl.String(),
+			fmt.Sprintf("must be between %d and %d seconds",
+				int64(minTTL.Seconds()), int64(maxTTL.Seconds())))
+	}
+	return nil
+}
+
+func validateMergePolicy(r Record) []*field.Error {
+	var errs []*field.Error
+	policyPath := field.NewPath("record").Child("mergePolicy")
+	keyPath := field.NewPath("record").Child("mergeKey")
+	switch r.MergePolicy {
+	case MergePolicyExclusive:
+		if r.MergeKey != "" {
+			errs = append(errs, field.Invalid(
+				keyPath, r.MergeKey, "only allowed with the Shared merge policy"))
+		}
+
+	case MergePolicyShared:
+		if r.MergeKey == "" {
+			errs = append(errs, field.Required(
+				keyPath, "required for the Shared merge policy"))
+		}
+		if r.hasCNAME() {
+			errs = append(errs, field.Invalid(
+				policyPath, r.MergePolicy, "CNAME records can not be shared"))
+		}
+
+	default:
+		errs = append(errs, field.NotSupported(policyPath, r.MergePolicy,
+			[]string{string(MergePolicyExclusive), string(MergePolicyShared)}))
+	}
+	return errs
 }
 
 func filterNil(fields []*field.Error, errs ...*field.Error) []*field.Error {
@@ -176,18 +439,18 @@ func filterNil(fields []*field.Error, errs ...*field.Error) []*field.Error {
 	return fields
 }
 
-func noA(a []string) *field.Error {
+func noA(fldPath *field.Path, a []string) *field.Error {
 	if len(a) == 0 {
 		return nil
 	}
-	path := field.NewPath("record").Child("a")
+	path := fldPath.Child("a")
 	return field.Invalid(path, a, "can not contain multiple types of records")
 }
 
-func validateA(a []string) []*field.Error {
+func validateA(fldPath *field.Path, a []string) []*field.Error {
 	var errs []*field.Error
 	for i, entry := range a {
-		path := field.NewPath("record").Child("a").Index(i)
+		path := fldPath.Child("a").Index(i)
 
 		ip := net.ParseIP(entry)
 		if ip == nil || ip.To4() == nil {
@@ -197,68 +460,204 @@ func validateA(a []string) []*field.Error {
 	return errs
 }
 
-func noAAAA(aaaa []string) *field.Error {
+func noAAAA(fldPath *field.Path, aaaa []string) *field.Error {
 	if len(aaaa) == 0 {
 		return nil
 	}
-	path := field.NewPath("record").Child("aaaa")
+	path := fldPath.Child("aaaa")
 	return field.Invalid(path, aaaa, "can not contain multiple types of records")
 }
 
-func validateAAAA(a []string) []*field.Error {
+func validateAAAA(fldPath *field.Path, a []string) []*field.Error {
 	var errs []*field.Error
 	for i, entry := range a {
-		path := field.NewPath("record").Child("aaaa").Index(i)
+		path := fldPath.Child("aaaa").Index(i)
 
 		ip := net.ParseIP(entry)
-		if ip == nil || ip.To16() == nil {
+		if ip == nil || ip.To4() != nil {
 			errs = append(errs, field.Invalid(path, entry, "not a valid IPv6 address"))
 		}
 	}
 	return errs
 }
 
-func noTXT(txt []string) *field.Error {
+func noTXT(fldPath *field.Path, txt []string) *field.Error {
 	if len(txt) == 0 {
 		return nil
 	}
-	path := field.NewPath("record").Child("txt")
+	path := fldPath.Child("txt")
 	return field.Invalid(path, txt, "can not contain multiple types of records")
 }
 
-func noCName(cname *string) *field.Error {
+func validateTXT(fldPath *field.Path, txt []string) []*field.Error {
+	var errs []*field.Error
+	for i, entry := range txt {
+		if len(entry) > maxTXTLength {
+			path := fldPath.Child("txt").Index(i)
+			errs = append(errs, field.TooLong(path, entry, maxTXTLength))
+		}
+	}
+	return errs
+}
+
+func noCName(fldPath *field.Path, cname *string) *field.Error {
 	if cname == nil {
 		return nil
 	}
-	path := field.NewPath("record").Child("cname")
+	path := fldPath.Child("cname")
 	return field.Invalid(path, cname, "can not contain multiple types of records")
 }
 
-func noNS(ns []string) *field.Error {
+func noCNameRef(fldPath *field.Path, ref *TargetRef) *field.Error {
+	if ref == nil {
+		return nil
+	}
+	path := fldPath.Child("cnameRef")
+	return field.Invalid(path, ref, "can not contain multiple types of records")
+}
+
+func validateCName(fldPath *field.Path, c RecordConfig) []*field.Error {
+	if c.CName != nil && c.CNameRef != nil {
+		return []*field.Error{field.Invalid(fldPath.Child("cnameRef"), c.CNameRef,
+			"can not be combined with cname")}
+	}
+	if c.CNameRef != nil {
+		return validateTargetRef(fldPath.Child("cnameRef"), *c.CNameRef)
+	}
+	return filterNil(nil, validateTarget(fldPath.Child("cname"), *c.CName))
+}
+
+func noNS(fldPath *field.Path, ns []string) *field.Error {
 	if len(ns) == 0 {
 		return nil
 	}
-	path := field.NewPath("record").Child("ns")
+	path := fldPath.Child("ns")
 	return field.Invalid(path, ns, "can not contain multiple types of records")
 }
 
-func noMX(mx []MX) *field.Error {
+func noNSRefs(fldPath *field.Path, refs []TargetRef) *field.Error {
+	if len(refs) == 0 {
+		return nil
+	}
+	path := fldPath.Child("nsRefs")
+	return field.Invalid(path, refs, "can not contain multiple types of records")
+}
+
+func validateNS(fldPath *field.Path, ns []string) []*field.Error {
+	var errs []*field.Error
+	for i, entry := range ns {
+		errs = filterNil(errs, validateTarget(fldPath.Child("ns").Index(i), entry))
+	}
+	return errs
+}
+
+func validateNSRefs(fldPath *field.Path, refs []TargetRef) []*field.Error {
+	var errs []*field.Error
+	for i, ref := range refs {
+		errs = append(errs, validateTargetRef(fldPath.Child("nsRefs").Index(i), ref)...)
+	}
+	return errs
+}
+
+func noMX(fldPath *field.Path, mx []MX) *field.Error {
 	if len(mx) == 0 {
 		return nil
 	}
-	path := field.NewPath("record").Child("mx")
+	path := fldPath.Child("mx")
 	return field.Invalid(path, mx, "can not contain multiple types of records")
 }
 
-func noSRV(srv []SRV) *field.Error {
+func validateMX(fldPath *field.Path, mx []MX) []*field.Error {
+	var errs []*field.Error
+	for i, entry := range mx {
+		path := fldPath.Child("mx").Index(i)
+		errs = filterNil(errs, validateUint16(path.Child("priority"), entry.Priority))
+		errs = append(errs, validateHost(path, entry.Host, entry.HostRef)...)
+	}
+	return errs
+}
+
+func noSRV(fldPath *field.Path, srv []SRV) *field.Error {
 	if len(srv) == 0 {
 		return nil
 	}
-	path := field.NewPath("record").Child("srv")
+	path := fldPath.Child("srv")
 	return field.Invalid(path, srv, "can not contain multiple types of records")
 }
 
+func validateSRV(fldPath *field.Path, srv []SRV) []*field.Error {
+	var errs []*field.Error
+	for i, entry := range srv {
+		path := fldPath.Child("srv").Index(i)
+		errs = filterNil(errs,
+			validateUint16(path.Child("priority"), entry.Priority),
+			validateUint16(path.Child("weight"), entry.Weight),
+			validateUint16(path.Child("port"), entry.Port),
+		)
+		errs = append(errs, validateHost(path, entry.Host, entry.HostRef)...)
+	}
+	return errs
+}
+
+func noAlias(fldPath *field.Path, alias *string) *field.Error {
+	if alias == nil {
+		return nil
+	}
+	path := fldPath.Child("alias")
+	return field.Invalid(path, alias, "can not contain multiple types of records")
+}
+
+func validateAlias(fldPath *field.Path, alias *string) []*field.Error {
+	return filterNil(nil, validateTarget(fldPath.Child("alias"), *alias))
+}
+
+// validateTarget checks that the target of a record is a fully qualified
+// domain name, as it is not relative to the zone.
+func validateTarget(path *field.Path, name string) *field.Error {
+	if _, ok := dns.IsDomainName(name); !ok || !dns.IsFqdn(name) {
+		return field.Invalid(path, name, "not a fully qualified domain name")
+	}
+	return nil
+}
+
+func validateUint16(path *field.Path, v int) *field.Error {
+	if v < 0 || v > math.MaxUint16 {
+		return field.Invalid(path, v, fmt.Sprintf("must be between 0 and %d", math.MaxUint16))
+	}
+	return nil
+}
+
+// validateHost checks that exactly one of host and hostRef is set.
+func validateHost(fldPath *field.Path, host string, ref *TargetRef) []*field.Error {
+	switch {
+	case host != "" && ref != nil:
+		return []*field.Error{field.Invalid(
+			fldPath.Child("hostRef"), ref, "can not be combined with host")}
+	case ref != nil:
+		return validateTargetRef(fldPath.Child("hostRef"), *ref)
+	case host == "":
+		return []*field.Error{field.Required(
+			fldPath.Child("host"), "host or hostRef is required")}
+	}
+	return filterNil(nil, validateTarget(fldPath.Child("host"), host))
+}
+
+func validateTargetRef(fldPath *field.Path, ref TargetRef) []*field.Error {
+	var errs []*field.Error
+	switch ref.Kind {
+	case TargetKindRecordSet, TargetKindService:
+	default:
+		errs = append(errs, field.NotSupported(fldPath.Child("kind"), ref.Kind,
+			[]string{string(TargetKindRecordSet), string(TargetKindService)}))
+	}
+	if ref.Name == "" {
+		errs = append(errs, field.Required(fldPath.Child("name"), ""))
+	}
+	return errs
+}
+
 func (r *RecordSet) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	recordSetClient = mgr.GetClient()
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		Complete()
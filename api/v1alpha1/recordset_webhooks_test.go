@@ -0,0 +1,112 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testRecordSet(config RecordConfig) *RecordSet {
+	return &RecordSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "www", Namespace: "default"},
+		Record: Record{
+			DNSName:      "www.example.com.",
+			TTL:          metav1.Duration{Duration: time.Minute},
+			RecordConfig: config,
+		},
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestRecordSetDefaultTargets(t *testing.T) {
+	r := testRecordSet(RecordConfig{CName: strPtr("lb.example.net")})
+	r.Record.RRsets = []RRset{
+		{RecordConfig: RecordConfig{MX: []MX{{Priority: 10, Host: "mail.example.com"}}}},
+		{RecordConfig: RecordConfig{SRV: []SRV{{Port: 443, Host: "web.example.com."}}}},
+		{RecordConfig: RecordConfig{NS: []string{"ns1.example.com"}}},
+		{RecordConfig: RecordConfig{Alias: strPtr("cdn.example.net")}},
+	}
+	r.Default()
+
+	for got, want := range map[string]string{
+		*r.Record.CName:                "lb.example.net.",
+		r.Record.RRsets[0].MX[0].Host:  "mail.example.com.",
+		r.Record.RRsets[1].SRV[0].Host: "web.example.com.",
+		r.Record.RRsets[2].NS[0]:       "ns1.example.com.",
+		*r.Record.RRsets[3].Alias:      "cdn.example.net.",
+	} {
+		if got != want {
+			t.Errorf("got target %q, want %q", got, want)
+		}
+	}
+
+	// relative targets of existing RecordSets are accepted after defaulting
+	old := testRecordSet(RecordConfig{CName: strPtr("lb.example.net")})
+	updated := old.DeepCopy()
+	updated.Labels = map[string]string{"team": "web"}
+	updated.Default()
+	if err := updated.ValidateUpdate(old); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRecordSetValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		config RecordConfig
+		// zeroTTL sets a TTL of 0
+		zeroTTL bool
+		// field of the expected error, empty if valid
+		field string
+	}{
+		{name: "A", config: RecordConfig{A: []string{"192.0.2.1"}}},
+		{name: "invalid A", config: RecordConfig{A: []string{"2001:db8::1"}}, field: "record.a[0]"},
+		{name: "invalid AAAA", config: RecordConfig{AAAA: []string{"192.0.2.1"}}, field: "record.aaaa[0]"},
+		{name: "invalid CNAME", config: RecordConfig{CName: strPtr("a..b")}, field: "record.cname"},
+		{name: "MX priority", config: RecordConfig{MX: []MX{{Priority: 70000, Host: "mail.example.com."}}},
+			field: "record.mx[0].priority"},
+		{name: "SRV port", config: RecordConfig{SRV: []SRV{{Port: -1, Host: "sip.example.com."}}},
+			field: "record.srv[0].port"},
+		{name: "long TXT", config: RecordConfig{TXT: []string{strings.Repeat("a", 1000)}}},
+		{name: "too long TXT", config: RecordConfig{TXT: []string{strings.Repeat("a", maxTXTLength+1)}},
+			field: "record.txt[0]"},
+		{name: "zero TTL", config: RecordConfig{A: []string{"192.0.2.1"}}, zeroTTL: true, field: "record.ttl"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := testRecordSet(test.config)
+			if test.zeroTTL {
+				r.Record.TTL.Duration = 0
+			}
+			r.Default()
+			err := r.ValidateCreate()
+			switch {
+			case test.field == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case test.field != "" && (err == nil || !strings.Contains(err.Error(), test.field)):
+				t.Errorf("expected an error for %s, got %v", test.field, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// RejectionRecorder is notified about objects rejected by the
+// validating webhooks, e.g. to count them and emit Events.
+type RejectionRecorder interface {
+	// RecordRejection is called with the rejected object, its kind and
+	// the validation errors. Objects rejected on creation have no UID yet.
+	RecordRejection(obj runtime.Object, kind string, allErrs field.ErrorList)
+}
+
+// rejectionRecorder is notified about rejected objects, if set.
+var rejectionRecorder RejectionRecorder
+
+// SetRejectionRecorder sets the RejectionRecorder of the validating
+// webhooks, it has to be called before the webhooks are set up.
+func SetRejectionRecorder(r RejectionRecorder) {
+	rejectionRecorder = r
+}
+
+// reject records the rejection of obj and returns the Invalid error
+// for the admission response.
+func reject(obj runtime.Object, kind, name string, allErrs field.ErrorList) error {
+	if rejectionRecorder != nil {
+		rejectionRecorder.RecordRejection(obj, kind, allErrs)
+	}
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: kind}, name, allErrs)
+}
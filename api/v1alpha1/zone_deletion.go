@@ -0,0 +1,89 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ZoneFinalizer is added to Zones and ClusterZones by the manager,
+// to apply their DeletionPolicy to the RecordSets of the zone.
+const ZoneFinalizer = "route42.thetechnick.ninja/zone-cleanup"
+
+// GetDeletionPolicy returns the DeletionPolicy, defaulting to Block.
+func (c ZoneConfig) GetDeletionPolicy() DeletionPolicy {
+	if c.DeletionPolicy == "" {
+		return DeletionPolicyBlock
+	}
+	return c.DeletionPolicy
+}
+
+// ExclusiveRecordSets returns the RecordSets that belong to the given
+// Zone or ClusterZone and to no other zone, so they are no longer served
+// when the zone is deleted. RecordSets already being deleted are skipped.
+func ExclusiveRecordSets(
+	ctx context.Context, c client.Reader, zone metav1.Object,
+) ([]RecordSet, error) {
+	var otherZones []string
+	clusterZoneList := &ClusterZoneList{}
+	if err := c.List(ctx, clusterZoneList); err != nil {
+		return nil, fmt.Errorf("listing ClusterZones: %w", err)
+	}
+	for _, other := range clusterZoneList.Items {
+		if zone.GetNamespace() != "" || other.Name != zone.GetName() {
+			otherZones = append(otherZones, other.Name)
+		}
+	}
+	zoneList := &ZoneList{}
+	if err := c.List(ctx, zoneList); err != nil {
+		return nil, fmt.Errorf("listing Zones: %w", err)
+	}
+	for _, other := range zoneList.Items {
+		if other.Namespace != zone.GetNamespace() || other.Name != zone.GetName() {
+			otherZones = append(otherZones, other.Name)
+		}
+	}
+
+	recordSetList := &RecordSetList{}
+	if err := c.List(ctx, recordSetList); err != nil {
+		return nil, fmt.Errorf("listing RecordSets: %w", err)
+	}
+	var recordSets []RecordSet
+	for _, recordSet := range recordSetList.Items {
+		if recordSet.DeletionTimestamp != nil || !recordSet.Record.InZone(zone.GetName()) {
+			continue
+		}
+		if inAnyZone(recordSet.Record, otherZones) {
+			continue
+		}
+		recordSets = append(recordSets, recordSet)
+	}
+	return recordSets, nil
+}
+
+func inAnyZone(r Record, zones []string) bool {
+	for _, zone := range zones {
+		if r.InZone(zone) {
+			return true
+		}
+	}
+	return false
+}
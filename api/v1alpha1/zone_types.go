@@ -24,20 +24,43 @@ import (
 // +kubebuilder:object:root=true
 // +kubebuilder:printcolumn:name="Master",type="string",JSONPath=".zone.soa.master"
 // +kubebuilder:printcolumn:name="Admin",type="string",JSONPath=".zone.soa.admin"
+// +kubebuilder:printcolumn:name="Serial",type="integer",JSONPath=".zone.soa.serial"
+// +kubebuilder:printcolumn:name="In Sync",type="boolean",JSONPath=".status.inSync"
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+// +kubebuilder:storageversion
 type Zone struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Zone ZoneConfig `json:"zone,omitempty"`
+	Zone   ZoneConfig `json:"zone,omitempty"`
+	Status ZoneStatus `json:"status,omitempty"`
 }
 
 // ZoneConfig holds Zone configuration settings.
 type ZoneConfig struct {
 	// start of authority record
 	SOA SOARecord `json:"soa"`
+	// AllowQuery lists the client CIDRs allowed to query the zone,
+	// all other clients are REFUSED. Empty allows all clients.
+	AllowQuery []string `json:"allowQuery,omitempty"`
+	// DeletionPolicy decides what happens to the RecordSets of the zone
+	// when it is deleted, defaults to Block.
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
 }
 
+// DeletionPolicy decides what happens to the RecordSets of a deleted zone.
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyBlock keeps the zone until all its RecordSets are deleted.
+	DeletionPolicyBlock DeletionPolicy = "Block"
+	// DeletionPolicyDelete deletes all RecordSets of the zone with it.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyOrphan keeps the RecordSets, which are no longer served.
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
 // SOARecord represents the SOA record for this zone.
 type SOARecord struct {
 	TTL         metav1.Duration `json:"ttl"`
@@ -50,6 +73,25 @@ type SOARecord struct {
 	NegativeTTL metav1.Duration `json:"negativeTTL"`
 }
 
+// ZoneStatus reports which agents serve the Zone.
+type ZoneStatus struct {
+	// ServingAgents lists the agents with a current heartbeat serving this zone.
+	ServingAgents []ServingAgent `json:"servingAgents,omitempty"`
+	// InSync is true when at least one agent serves the zone
+	// and all serving agents serve the current serial.
+	InSync bool `json:"inSync"`
+}
+
+// ServingAgent is an agent serving a zone.
+type ServingAgent struct {
+	// Name of the agent, usually its Pod name.
+	Name string `json:"name"`
+	// Serial of the zone served by the agent.
+	Serial int `json:"serial"`
+	// LastHeartbeat is the time the agent last renewed its Lease.
+	LastHeartbeat metav1.Time `json:"lastHeartbeat"`
+}
+
 // ZoneList contains a list of Zone
 // +kubebuilder:object:root=true
 type ZoneList struct {
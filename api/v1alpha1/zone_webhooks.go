@@ -17,45 +17,53 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"strings"
 	"time"
 
 	"github.com/miekg/dns"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
 // +kubebuilder:webhook:path=/mutate-route42-thetechnick-ninja-v1alpha1-zone,mutating=true,failurePolicy=fail,groups=route42.thetechnick.ninja,resources=zones,verbs=create;update,versions=v1alpha1,name=mutation-zone.route42.thetechnick.ninja
 
-// +kubebuilder:webhook:verbs=create;update,path=/validate-route42-thetechnick-ninja-v1alpha1-zone,mutating=false,failurePolicy=fail,groups=route42.thetechnick.ninja,resources=zones,versions=v1alpha1,name=validation-zone.route42.thetechnick.ninja
+// +kubebuilder:webhook:verbs=create;update;delete,path=/validate-route42-thetechnick-ninja-v1alpha1-zone,mutating=false,failurePolicy=fail,groups=route42.thetechnick.ninja,resources=zones,versions=v1alpha1,name=validation-zone.route42.thetechnick.ninja
 
 var (
 	zonelog                   = logf.Log.WithName("zone-resource")
 	_       webhook.Defaulter = (*Zone)(nil)
 	_       webhook.Validator = (*Zone)(nil)
+
+	// zoneClient is used to check for RecordSets of deleted zones.
+	zoneClient client.Reader
+)
+
+const (
+	// minSOARefresh is the lowest refresh and retry interval accepted.
+	minSOARefresh = time.Minute
+	// minSOAExpire is the lowest expire interval accepted.
+	minSOAExpire = time.Hour
+	// maxSOASerial is the highest serial, as it is an unsigned 32 bit integer.
+	maxSOASerial = math.MaxUint32
 )
 
 func (z *Zone) Default() {
 	zonelog.Info("default", "Zone",
 		types.NamespacedName{Name: z.Name, Namespace: z.Namespace})
 
-	if z.Zone.SOA.Refresh.Duration == 0 {
-		z.Zone.SOA.Refresh.Duration = time.Hour * 24
-	}
-	if z.Zone.SOA.Retry.Duration == 0 {
-		z.Zone.SOA.Retry.Duration = time.Hour * 2
-	}
-	if z.Zone.SOA.Expire.Duration == 0 {
-		z.Zone.SOA.Expire.Duration = time.Hour * 1000
-	}
-	if z.Zone.SOA.NegativeTTL.Duration == 0 {
-		z.Zone.SOA.NegativeTTL.Duration = time.Hour * 24 * 2
-	}
+	z.Zone.Default()
 }
 
 func (z *Zone) ValidateCreate() error {
@@ -73,7 +81,7 @@ func (z *Zone) ValidateUpdate(old runtime.Object) error {
 func (z *Zone) ValidateDelete() error {
 	zonelog.Info("validate delete", "Zone",
 		types.NamespacedName{Name: z.Name, Namespace: z.Namespace})
-	return nil
+	return validateZoneDelete(z, "Zone", z.Zone)
 }
 
 func (z *Zone) validate(old *Zone) error {
@@ -83,30 +91,176 @@ func (z *Zone) validate(old *Zone) error {
 		field.NewPath("metadata").Child("name"), z.Name); err != nil {
 		allErrs = append(allErrs, err)
 	}
-	if err := validateName(
-		field.NewPath("zone").Child("soa").Child("master"), z.Zone.SOA.Master); err != nil {
-		allErrs = append(allErrs, err)
+	var oldZone *ZoneConfig
+	if old != nil {
+		oldZone = &old.Zone
 	}
-	if err := validateName(
-		field.NewPath("zone").Child("soa").Child("admin"), z.Zone.SOA.Admin); err != nil {
-		allErrs = append(allErrs, err)
+	// metadata-only updates, like adding the finalizer, must not be blocked
+	// by zones created before stricter validation
+	if z.Zone.changedFrom(oldZone) {
+		allErrs = append(allErrs, z.Zone.validate(field.NewPath("zone"), oldZone)...)
 	}
 
 	if len(allErrs) == 0 {
 		return nil
 	}
 
-	return apierrors.NewInvalid(
-		schema.GroupKind{Group: GroupVersion.Group, Kind: "Zone"},
-		z.Name, allErrs)
+	return reject(z, "Zone", z.Name, allErrs)
 }
 
 func (z *Zone) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	zoneClient = mgr.GetClient()
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(z).
 		Complete()
 }
 
+// Default sets default values for unset SOA timers.
+func (c *ZoneConfig) Default() {
+	if c.SOA.Refresh.Duration == 0 {
+		c.SOA.Refresh.Duration = time.Hour * 24
+	}
+	if c.SOA.Retry.Duration == 0 {
+		c.SOA.Retry.Duration = time.Hour * 2
+	}
+	if c.SOA.Expire.Duration == 0 {
+		c.SOA.Expire.Duration = time.Hour * 1000
+	}
+	if c.SOA.NegativeTTL.Duration == 0 {
+		c.SOA.NegativeTTL.Duration = time.Hour * 24 * 2
+	}
+	c.DeletionPolicy = c.GetDeletionPolicy()
+}
+
+// changedFrom returns true if the configuration changed from old,
+// ignoring defaults the mutating webhook applied on top of old.
+func (c ZoneConfig) changedFrom(old *ZoneConfig) bool {
+	if old == nil {
+		return true
+	}
+	defaulted := *old
+	defaulted.Default()
+	return !reflect.DeepEqual(defaulted, c)
+}
+
+// validate checks the zone configuration.
+// On updates, old is the previous configuration the serial must increase from.
+func (c *ZoneConfig) validate(path *field.Path, old *ZoneConfig) field.ErrorList {
+	var allErrs field.ErrorList
+	soaPath := path.Child("soa")
+	if err := validateName(soaPath.Child("master"), c.SOA.Master); err != nil {
+		allErrs = append(allErrs, err)
+	}
+	if err := validateName(soaPath.Child("admin"), c.SOA.Admin); err != nil {
+		allErrs = append(allErrs, err)
+	}
+	allErrs = append(allErrs, c.SOA.validateTimers(soaPath)...)
+	if c.SOA.Serial < 0 || int64(c.SOA.Serial) > maxSOASerial {
+		allErrs = append(allErrs, field.Invalid(soaPath.Child("serial"), c.SOA.Serial,
+			fmt.Sprintf("must be between 0 and %d", uint32(maxSOASerial))))
+	} else if old != nil && !serialIncreased(old.SOA.Serial, c.SOA.Serial) {
+		allErrs = append(allErrs, field.Invalid(soaPath.Child("serial"), c.SOA.Serial,
+			fmt.Sprintf("must not decrease from %d, see RFC 1982 serial number arithmetic",
+				old.SOA.Serial)))
+	}
+	for i, cidr := range c.AllowQuery {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			allErrs = append(allErrs, field.Invalid(
+				path.Child("allowQuery").Index(i), cidr, "not a valid CIDR"))
+		}
+	}
+	switch c.DeletionPolicy {
+	case DeletionPolicyBlock, DeletionPolicyDelete, DeletionPolicyOrphan:
+	default:
+		allErrs = append(allErrs, field.NotSupported(path.Child("deletionPolicy"), c.DeletionPolicy,
+			[]string{string(DeletionPolicyBlock), string(DeletionPolicyDelete), string(DeletionPolicyOrphan)}))
+	}
+	return allErrs
+}
+
+// validateTimers checks that secondaries retry a failed refresh before
+// the next regular one, and that the zone does not expire in between.
+func (soa SOARecord) validateTimers(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if soa.TTL.Duration > maxTTL {
+		allErrs = append(allErrs, field.Invalid(path.Child("ttl"), soa.TTL.Duration.String(),
+			fmt.Sprintf("must be at most %d seconds", int64(maxTTL.Seconds()))))
+	}
+	allErrs = filterNil(allErrs,
+		validateTimer(path.Child("refresh"), soa.Refresh.Duration, minSOARefresh),
+		validateTimer(path.Child("retry"), soa.Retry.Duration, minSOARefresh),
+		validateTimer(path.Child("expire"), soa.Expire.Duration, minSOAExpire),
+		validateTTL(path.Child("negativeTTL"), soa.NegativeTTL.Duration),
+	)
+	if len(allErrs) > 0 {
+		return allErrs
+	}
+
+	if soa.Retry.Duration >= soa.Refresh.Duration {
+		allErrs = append(allErrs, field.Invalid(path.Child("retry"), soa.Retry.Duration.String(),
+			fmt.Sprintf("must be less than refresh (%s)", soa.Refresh.Duration)))
+	}
+	if soa.Expire.Duration <= soa.Refresh.Duration {
+		allErrs = append(allErrs, field.Invalid(path.Child("expire"), soa.Expire.Duration.String(),
+			fmt.Sprintf("must be greater than refresh (%s)", soa.Refresh.Duration)))
+	}
+	return allErrs
+}
+
+func validateTimer(path *field.Path, d, min time.Duration) *field.Error {
+	if d < min || d > maxTTL {
+		return field.Invalid(path, d.String(), fmt.Sprintf("must be between %d and %d seconds",
+			int64(min.Seconds()), int64(maxTTL.Seconds())))
+	}
+	return nil
+}
+
+// serialIncreased returns true if the serial is unchanged or increased
+// from old, following the serial number arithmetic of RFC 1982,
+// so the serial may wrap around.
+func serialIncreased(old, serial int) bool {
+	const half = 1 << 31
+	s1, s2 := int64(old), int64(serial)
+	return s1 == s2 ||
+		(s1 < s2 && s2-s1 < half) ||
+		(s1 > s2 && s1-s2 > half)
+}
+
+// zoneObject is a Zone or ClusterZone.
+type zoneObject interface {
+	runtime.Object
+	metav1.Object
+}
+
+// validateZoneDelete denies deleting a Zone or ClusterZone with the Block
+// DeletionPolicy, while RecordSets are only served by this zone.
+// The manager applies the other policies with a finalizer.
+func validateZoneDelete(obj zoneObject, kind string, config ZoneConfig) error {
+	if zoneClient == nil || config.GetDeletionPolicy() != DeletionPolicyBlock {
+		return nil
+	}
+
+	recordSets, err := ExclusiveRecordSets(context.Background(), zoneClient, obj)
+	if err != nil {
+		return apierrors.NewInternalError(err)
+	}
+	if len(recordSets) == 0 {
+		return nil
+	}
+
+	msg := fmt.Sprintf("zone still has %d RecordSets, delete them first", len(recordSets))
+	if len(recordSets) <= 3 {
+		names := make([]string, len(recordSets))
+		for i, recordSet := range recordSets {
+			names[i] = recordSet.Namespace + "/" + recordSet.Name
+		}
+		msg = fmt.Sprintf("zone still has RecordSets %s, delete them first",
+			strings.Join(names, ", "))
+	}
+	return reject(obj, kind, obj.GetName(), field.ErrorList{
+		field.Forbidden(field.NewPath("metadata").Child("name"), msg)})
+}
+
 func validateName(path *field.Path, host string) *field.Error {
 	_, ok := dns.IsDomainName(host)
 	if !ok {
@@ -0,0 +1,168 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func testZone(name string) *Zone {
+	z := &Zone{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+	z.Zone.SOA = SOARecord{
+		TTL:    metav1.Duration{Duration: time.Minute},
+		Master: "ns1." + name + ".",
+		Admin:  "hostmaster." + name + ".",
+		Serial: 1,
+	}
+	z.Default()
+	return z
+}
+
+func TestZoneValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(soa *SOARecord)
+		// field of the expected error, empty if valid
+		field string
+	}{
+		{name: "defaults", modify: func(soa *SOARecord) {}},
+		{name: "retry not below refresh", field: "zone.soa.retry", modify: func(soa *SOARecord) {
+			soa.Retry.Duration = soa.Refresh.Duration
+		}},
+		{name: "expire not above refresh", field: "zone.soa.expire", modify: func(soa *SOARecord) {
+			soa.Expire.Duration = soa.Refresh.Duration
+		}},
+		{name: "refresh too short", field: "zone.soa.refresh", modify: func(soa *SOARecord) {
+			soa.Refresh.Duration = 30 * time.Second
+		}},
+		{name: "expire too short", field: "zone.soa.expire", modify: func(soa *SOARecord) {
+			soa.Expire.Duration = 30 * time.Minute
+		}},
+		{name: "TTL too long", field: "zone.soa.ttl", modify: func(soa *SOARecord) {
+			soa.TTL.Duration = maxTTL + time.Second
+		}},
+		{name: "negative serial", field: "zone.soa.serial", modify: func(soa *SOARecord) {
+			soa.Serial = -1
+		}},
+		{name: "serial too large", field: "zone.soa.serial", modify: func(soa *SOARecord) {
+			soa.Serial = maxSOASerial + 1
+		}},
+		{name: "invalid master", field: "zone.soa.master", modify: func(soa *SOARecord) {
+			soa.Master = "ns1..example.com."
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			z := testZone("example.com")
+			test.modify(&z.Zone.SOA)
+			err := z.ValidateCreate()
+			switch {
+			case test.field == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case test.field != "" && (err == nil || !strings.Contains(err.Error(), test.field)):
+				t.Errorf("expected an error for %s, got %v", test.field, err)
+			}
+		})
+	}
+}
+
+func TestZoneSerialUpdate(t *testing.T) {
+	tests := []struct {
+		old, serial int
+		valid       bool
+	}{
+		{old: 1, serial: 1, valid: true},
+		{old: 1, serial: 2, valid: true},
+		{old: 2, serial: 1},
+		// serial number arithmetic wraps around
+		{old: maxSOASerial, serial: 5, valid: true},
+		{old: 5, serial: maxSOASerial},
+		// increments must be less than 2^31
+		{old: 1, serial: 1 + 1<<31},
+	}
+	for _, test := range tests {
+		old := testZone("example.com")
+		old.Zone.SOA.Serial = test.old
+		z := old.DeepCopy()
+		z.Zone.SOA.Serial = test.serial
+		if err := z.ValidateUpdate(old); (err == nil) != test.valid {
+			t.Errorf("serial %d to %d: valid %v, got %v", test.old, test.serial, test.valid, err)
+		}
+	}
+
+	// metadata-only updates pass, even with a configuration
+	// that is no longer valid
+	old := testZone("example.com")
+	old.Zone.SOA.Retry.Duration = old.Zone.SOA.Refresh.Duration
+	z := old.DeepCopy()
+	z.Finalizers = []string{ZoneFinalizer}
+	if err := z.ValidateUpdate(old); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestZoneDeleteValidation(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = AddToScheme(scheme)
+
+	recordSet := func(name, dnsName string) *RecordSet {
+		return &RecordSet{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Record: Record{
+				DNSName:      dnsName,
+				TTL:          metav1.Duration{Duration: time.Minute},
+				RecordConfig: RecordConfig{A: []string{"192.0.2.1"}},
+			},
+		}
+	}
+	used := testZone("example.com")
+	unused := testZone("example.org")
+	// www.shared.example.net is kept by the other zone
+	shared := testZone("shared.example.net")
+	parent := testZone("example.net")
+	orphaning := testZone("example.io")
+	orphaning.Zone.DeletionPolicy = DeletionPolicyOrphan
+
+	defer func() { zoneClient = nil }()
+	zoneClient = fake.NewFakeClientWithScheme(scheme,
+		used, unused, shared, parent, orphaning,
+		recordSet("www", "www.example.com."),
+		recordSet("shared", "www.shared.example.net."),
+		recordSet("io", "www.example.io."),
+	)
+
+	tests := []struct {
+		zone  *Zone
+		valid bool
+	}{
+		{zone: used},
+		{zone: unused, valid: true},
+		{zone: shared, valid: true},
+		{zone: orphaning, valid: true},
+	}
+	for _, test := range tests {
+		if err := test.zone.ValidateDelete(); (err == nil) != test.valid {
+			t.Errorf("%s: valid %v, got %v", test.zone.Name, test.valid, err)
+		}
+	}
+}
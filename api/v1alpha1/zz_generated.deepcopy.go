@@ -21,12 +21,77 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterZone) DeepCopyInto(out *ClusterZone) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Zone.DeepCopyInto(&out.Zone)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterZone.
+func (in *ClusterZone) DeepCopy() *ClusterZone {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterZone)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterZone) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterZoneList) DeepCopyInto(out *ClusterZoneList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterZone, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterZoneList.
+func (in *ClusterZoneList) DeepCopy() *ClusterZoneList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterZoneList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterZoneList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MX) DeepCopyInto(out *MX) {
 	*out = *in
+	if in.HostRef != nil {
+		in, out := &in.HostRef, &out.HostRef
+		*out = new(TargetRef)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MX.
@@ -39,11 +104,39 @@ func (in *MX) DeepCopy() *MX {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RRset) DeepCopyInto(out *RRset) {
+	*out = *in
+	if in.TTL != nil {
+		in, out := &in.TTL, &out.TTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	in.RecordConfig.DeepCopyInto(&out.RecordConfig)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RRset.
+func (in *RRset) DeepCopy() *RRset {
+	if in == nil {
+		return nil
+	}
+	out := new(RRset)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Record) DeepCopyInto(out *Record) {
 	*out = *in
 	out.TTL = in.TTL
 	in.RecordConfig.DeepCopyInto(&out.RecordConfig)
+	if in.RRsets != nil {
+		in, out := &in.RRsets, &out.RRsets
+		*out = make([]RRset, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Record.
@@ -79,20 +172,39 @@ func (in *RecordConfig) DeepCopyInto(out *RecordConfig) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.CNameRef != nil {
+		in, out := &in.CNameRef, &out.CNameRef
+		*out = new(TargetRef)
+		**out = **in
+	}
 	if in.NS != nil {
 		in, out := &in.NS, &out.NS
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.NSRefs != nil {
+		in, out := &in.NSRefs, &out.NSRefs
+		*out = make([]TargetRef, len(*in))
+		copy(*out, *in)
+	}
 	if in.MX != nil {
 		in, out := &in.MX, &out.MX
 		*out = make([]MX, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.SRV != nil {
 		in, out := &in.SRV, &out.SRV
 		*out = make([]SRV, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Alias != nil {
+		in, out := &in.Alias, &out.Alias
+		*out = new(string)
+		**out = **in
 	}
 }
 
@@ -112,6 +224,7 @@ func (in *RecordSet) DeepCopyInto(out *RecordSet) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Record.DeepCopyInto(&out.Record)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RecordSet.
@@ -132,6 +245,22 @@ func (in *RecordSet) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RecordSetCondition) DeepCopyInto(out *RecordSetCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RecordSetCondition.
+func (in *RecordSetCondition) DeepCopy() *RecordSetCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(RecordSetCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RecordSetList) DeepCopyInto(out *RecordSetList) {
 	*out = *in
@@ -164,6 +293,28 @@ func (in *RecordSetList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RecordSetStatus) DeepCopyInto(out *RecordSetStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]RecordSetCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RecordSetStatus.
+func (in *RecordSetStatus) DeepCopy() *RecordSetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RecordSetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SOARecord) DeepCopyInto(out *SOARecord) {
 	*out = *in
@@ -187,6 +338,11 @@ func (in *SOARecord) DeepCopy() *SOARecord {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SRV) DeepCopyInto(out *SRV) {
 	*out = *in
+	if in.HostRef != nil {
+		in, out := &in.HostRef, &out.HostRef
+		*out = new(TargetRef)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SRV.
@@ -199,12 +355,28 @@ func (in *SRV) DeepCopy() *SRV {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TargetRef) DeepCopyInto(out *TargetRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetRef.
+func (in *TargetRef) DeepCopy() *TargetRef {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Zone) DeepCopyInto(out *Zone) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Zone = in.Zone
+	in.Zone.DeepCopyInto(&out.Zone)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Zone.
@@ -229,6 +401,11 @@ func (in *Zone) DeepCopyObject() runtime.Object {
 func (in *ZoneConfig) DeepCopyInto(out *ZoneConfig) {
 	*out = *in
 	out.SOA = in.SOA
+	if in.AllowQuery != nil {
+		in, out := &in.AllowQuery, &out.AllowQuery
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZoneConfig.
@@ -272,3 +449,41 @@ func (in *ZoneList) DeepCopyObject() runtime.Object {
 	}
 	return nil
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServingAgent) DeepCopyInto(out *ServingAgent) {
+	*out = *in
+	in.LastHeartbeat.DeepCopyInto(&out.LastHeartbeat)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServingAgent.
+func (in *ServingAgent) DeepCopy() *ServingAgent {
+	if in == nil {
+		return nil
+	}
+	out := new(ServingAgent)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZoneStatus) DeepCopyInto(out *ZoneStatus) {
+	*out = *in
+	if in.ServingAgents != nil {
+		in, out := &in.ServingAgents, &out.ServingAgents
+		*out = make([]ServingAgent, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZoneStatus.
+func (in *ZoneStatus) DeepCopy() *ZoneStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ZoneStatus)
+	in.DeepCopyInto(out)
+	return out
+}
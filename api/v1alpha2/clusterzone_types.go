@@ -0,0 +1,51 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterZone is the Schema for the clusterzones API.
+// ClusterZones are shared across all namespaces.
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Master",type="string",JSONPath=".spec.soa.master"
+// +kubebuilder:printcolumn:name="Admin",type="string",JSONPath=".spec.soa.admin"
+// +kubebuilder:printcolumn:name="Serial",type="integer",JSONPath=".spec.soa.serial"
+// +kubebuilder:printcolumn:name="In Sync",type="boolean",JSONPath=".status.inSync"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+type ClusterZone struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ZoneSpec   `json:"spec,omitempty"`
+	Status ZoneStatus `json:"status,omitempty"`
+}
+
+// ClusterZoneList contains a list of ClusterZone
+// +kubebuilder:object:root=true
+type ClusterZoneList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterZone `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterZone{}, &ClusterZoneList{})
+}
@@ -0,0 +1,251 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/thetechnick/route42/api/v1alpha1"
+)
+
+var (
+	_ conversion.Convertible = (*Zone)(nil)
+	_ conversion.Convertible = (*ClusterZone)(nil)
+	_ conversion.Convertible = (*RecordSet)(nil)
+)
+
+// ConvertTo converts this Zone to the hub version.
+func (src *Zone) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.Zone)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Zone = zoneSpecToV1alpha1(src.Spec)
+	dst.Status = zoneStatusToV1alpha1(src.Status)
+	return nil
+}
+
+// ConvertFrom converts from the hub version to this Zone.
+func (dst *Zone) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.Zone)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = zoneSpecFromV1alpha1(src.Zone)
+	dst.Status = zoneStatusFromV1alpha1(src.Status)
+	return nil
+}
+
+// ConvertTo converts this ClusterZone to the hub version.
+func (src *ClusterZone) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.ClusterZone)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Zone = zoneSpecToV1alpha1(src.Spec)
+	dst.Status = zoneStatusToV1alpha1(src.Status)
+	return nil
+}
+
+// ConvertFrom converts from the hub version to this ClusterZone.
+func (dst *ClusterZone) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.ClusterZone)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = zoneSpecFromV1alpha1(src.Zone)
+	dst.Status = zoneStatusFromV1alpha1(src.Status)
+	return nil
+}
+
+// ConvertTo converts this RecordSet to the hub version.
+// The v1alpha1 record type is derived from the records.
+func (src *RecordSet) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.RecordSet)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Record = v1alpha1.Record{
+		DNSName:      src.Spec.DNSName,
+		ZoneRef:      src.Spec.ZoneRef,
+		TTL:          src.Spec.TTL,
+		RecordConfig: recordsToV1alpha1(src.Spec.Records),
+		MergePolicy:  v1alpha1.MergePolicy(src.Spec.MergePolicy),
+		MergeKey:     src.Spec.MergeKey,
+	}
+	for _, rrset := range src.Spec.RRsets {
+		dst.Record.RRsets = append(dst.Record.RRsets, v1alpha1.RRset{
+			TTL:          rrset.TTL,
+			RecordConfig: recordsToV1alpha1(rrset.Records),
+		})
+	}
+	if dst.Record.GetType() != v1alpha1.RecordTypeUnknown {
+		dst.Record.Type = dst.Record.GetType()
+	}
+
+	dst.Status = v1alpha1.RecordSetStatus{}
+	for _, cond := range src.Status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, v1alpha1.RecordSetCondition{
+			Type:               v1alpha1.RecordSetConditionType(cond.Type),
+			Status:             v1alpha1.ConditionStatus(cond.Status),
+			LastTransitionTime: cond.LastTransitionTime,
+			Reason:             cond.Reason,
+			Message:            cond.Message,
+		})
+	}
+	return nil
+}
+
+// ConvertFrom converts from the hub version to this RecordSet.
+func (dst *RecordSet) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.RecordSet)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = RecordSetSpec{
+		DNSName:     src.Record.DNSName,
+		ZoneRef:     src.Record.ZoneRef,
+		TTL:         src.Record.TTL,
+		Records:     recordsFromV1alpha1(src.Record.RecordConfig),
+		MergePolicy: MergePolicy(src.Record.MergePolicy),
+		MergeKey:    src.Record.MergeKey,
+	}
+	for _, rrset := range src.Record.RRsets {
+		dst.Spec.RRsets = append(dst.Spec.RRsets, RRset{
+			TTL:     rrset.TTL,
+			Records: recordsFromV1alpha1(rrset.RecordConfig),
+		})
+	}
+
+	dst.Status = RecordSetStatus{}
+	for _, cond := range src.Status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, RecordSetCondition{
+			Type:               RecordSetConditionType(cond.Type),
+			Status:             ConditionStatus(cond.Status),
+			LastTransitionTime: cond.LastTransitionTime,
+			Reason:             cond.Reason,
+			Message:            cond.Message,
+		})
+	}
+	return nil
+}
+
+func recordsToV1alpha1(records Records) v1alpha1.RecordConfig {
+	config := v1alpha1.RecordConfig{
+		A:        records.A,
+		AAAA:     records.AAAA,
+		TXT:      records.TXT,
+		CName:    records.CName,
+		CNameRef: targetRefToV1alpha1(records.CNameRef),
+		NS:       records.NS,
+		Alias:    records.Alias,
+	}
+	for i := range records.NSRefs {
+		config.NSRefs = append(config.NSRefs, *targetRefToV1alpha1(&records.NSRefs[i]))
+	}
+	for _, mx := range records.MX {
+		config.MX = append(config.MX, v1alpha1.MX{
+			Priority: mx.Priority,
+			Host:     mx.Host,
+			HostRef:  targetRefToV1alpha1(mx.HostRef),
+		})
+	}
+	for _, srv := range records.SRV {
+		config.SRV = append(config.SRV, v1alpha1.SRV{
+			Priority: srv.Priority,
+			Weight:   srv.Weight,
+			Port:     srv.Port,
+			Host:     srv.Host,
+			HostRef:  targetRefToV1alpha1(srv.HostRef),
+		})
+	}
+	return config
+}
+
+func recordsFromV1alpha1(config v1alpha1.RecordConfig) Records {
+	records := Records{
+		A:        config.A,
+		AAAA:     config.AAAA,
+		TXT:      config.TXT,
+		CName:    config.CName,
+		CNameRef: targetRefFromV1alpha1(config.CNameRef),
+		NS:       config.NS,
+		Alias:    config.Alias,
+	}
+	for i := range config.NSRefs {
+		records.NSRefs = append(records.NSRefs, *targetRefFromV1alpha1(&config.NSRefs[i]))
+	}
+	for _, mx := range config.MX {
+		records.MX = append(records.MX, MX{
+			Priority: mx.Priority,
+			Host:     mx.Host,
+			HostRef:  targetRefFromV1alpha1(mx.HostRef),
+		})
+	}
+	for _, srv := range config.SRV {
+		records.SRV = append(records.SRV, SRV{
+			Priority: srv.Priority,
+			Weight:   srv.Weight,
+			Port:     srv.Port,
+			Host:     srv.Host,
+			HostRef:  targetRefFromV1alpha1(srv.HostRef),
+		})
+	}
+	return records
+}
+
+func targetRefToV1alpha1(ref *TargetRef) *v1alpha1.TargetRef {
+	if ref == nil {
+		return nil
+	}
+	return &v1alpha1.TargetRef{
+		Kind:      v1alpha1.TargetKind(ref.Kind),
+		Name:      ref.Name,
+		Namespace: ref.Namespace,
+	}
+}
+
+func targetRefFromV1alpha1(ref *v1alpha1.TargetRef) *TargetRef {
+	if ref == nil {
+		return nil
+	}
+	return &TargetRef{
+		Kind:      TargetKind(ref.Kind),
+		Name:      ref.Name,
+		Namespace: ref.Namespace,
+	}
+}
+
+func zoneSpecToV1alpha1(spec ZoneSpec) v1alpha1.ZoneConfig {
+	return v1alpha1.ZoneConfig{
+		SOA:            v1alpha1.SOARecord(spec.SOA),
+		AllowQuery:     spec.AllowQuery,
+		DeletionPolicy: v1alpha1.DeletionPolicy(spec.DeletionPolicy),
+	}
+}
+
+func zoneSpecFromV1alpha1(config v1alpha1.ZoneConfig) ZoneSpec {
+	return ZoneSpec{
+		SOA:            SOARecord(config.SOA),
+		AllowQuery:     config.AllowQuery,
+		DeletionPolicy: DeletionPolicy(config.DeletionPolicy),
+	}
+}
+
+func zoneStatusToV1alpha1(status ZoneStatus) v1alpha1.ZoneStatus {
+	out := v1alpha1.ZoneStatus{InSync: status.InSync}
+	for _, agent := range status.ServingAgents {
+		out.ServingAgents = append(out.ServingAgents, v1alpha1.ServingAgent(agent))
+	}
+	return out
+}
+
+func zoneStatusFromV1alpha1(status v1alpha1.ZoneStatus) ZoneStatus {
+	out := ZoneStatus{InSync: status.InSync}
+	for _, agent := range status.ServingAgents {
+		out.ServingAgents = append(out.ServingAgents, ServingAgent(agent))
+	}
+	return out
+}
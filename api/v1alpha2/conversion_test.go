@@ -0,0 +1,335 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/thetechnick/route42/api/v1alpha1"
+)
+
+var (
+	testTime = metav1.NewTime(time.Date(2019, 11, 1, 12, 0, 0, 0, time.UTC))
+	testSOA  = v1alpha1.SOARecord{
+		TTL:         metav1.Duration{Duration: 100 * time.Second},
+		Master:      "ns1.example.com.",
+		Admin:       "hostmaster.example.com.",
+		Serial:      42,
+		Refresh:     metav1.Duration{Duration: 24 * time.Hour},
+		Retry:       metav1.Duration{Duration: 2 * time.Hour},
+		Expire:      metav1.Duration{Duration: 1000 * time.Hour},
+		NegativeTTL: metav1.Duration{Duration: 48 * time.Hour},
+	}
+	testZoneStatus = v1alpha1.ZoneStatus{
+		ServingAgents: []v1alpha1.ServingAgent{
+			{Name: "agent-1", Serial: 42, LastHeartbeat: testTime},
+			{Name: "agent-2", Serial: 41, LastHeartbeat: testTime},
+		},
+	}
+	testCNAME = "www.example.com."
+	testAlias = "lb.example.net."
+)
+
+func testRecordSet(config v1alpha1.RecordConfig, recordType v1alpha1.RecordType) *v1alpha1.RecordSet {
+	return &v1alpha1.RecordSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test", Namespace: "default", Labels: map[string]string{"app": "test"},
+		},
+		Record: v1alpha1.Record{
+			DNSName:      "test.example.com.",
+			TTL:          metav1.Duration{Duration: time.Minute},
+			RecordConfig: config,
+			Type:         recordType,
+			MergePolicy:  v1alpha1.MergePolicyShared,
+			MergeKey:     "pool",
+		},
+		Status: v1alpha1.RecordSetStatus{
+			Conditions: []v1alpha1.RecordSetCondition{{
+				Type:               v1alpha1.RecordSetConflict,
+				Status:             v1alpha1.ConditionFalse,
+				LastTransitionTime: testTime,
+				Reason:             "Accepted",
+				Message:            "no conflicts",
+			}},
+		},
+	}
+}
+
+func testMultiRecordSet(rrsets ...v1alpha1.RRset) *v1alpha1.RecordSet {
+	recordSet := testRecordSet(v1alpha1.RecordConfig{}, v1alpha1.RecordTypeMulti)
+	recordSet.Record.RRsets = rrsets
+	return recordSet
+}
+
+// Hub objects must survive a conversion to v1alpha2 and back.
+// v1alpha1 objects are defaulted by the webhook, so Type is always set.
+func TestConvertFromHub(t *testing.T) {
+	tests := []struct {
+		name  string
+		hub   conversion.Hub
+		spoke conversion.Convertible
+	}{
+		{
+			name: "Zone",
+			hub: &v1alpha1.Zone{
+				ObjectMeta: metav1.ObjectMeta{Name: "example.com", Namespace: "default"},
+				Zone: v1alpha1.ZoneConfig{
+					SOA:            testSOA,
+					AllowQuery:     []string{"10.0.0.0/8", "fd00::/8"},
+					DeletionPolicy: v1alpha1.DeletionPolicyDelete,
+				},
+				Status: testZoneStatus,
+			},
+			spoke: &Zone{},
+		},
+		{
+			name: "ClusterZone",
+			hub: &v1alpha1.ClusterZone{
+				ObjectMeta: metav1.ObjectMeta{Name: "example.com"},
+				Zone:       v1alpha1.ZoneConfig{SOA: testSOA},
+				Status:     v1alpha1.ZoneStatus{InSync: true},
+			},
+			spoke: &ClusterZone{},
+		},
+		{
+			name: "A RecordSet",
+			hub: testRecordSet(v1alpha1.RecordConfig{
+				A: []string{"192.0.2.1", "192.0.2.2"},
+			}, v1alpha1.RecordTypeA),
+			spoke: &RecordSet{},
+		},
+		{
+			name: "AAAA RecordSet",
+			hub: testRecordSet(v1alpha1.RecordConfig{
+				AAAA: []string{"2001:db8::1"},
+			}, v1alpha1.RecordTypeAAAA),
+			spoke: &RecordSet{},
+		},
+		{
+			name: "TXT RecordSet",
+			hub: testRecordSet(v1alpha1.RecordConfig{
+				TXT: []string{"v=spf1 -all"},
+			}, v1alpha1.RecordTypeTXT),
+			spoke: &RecordSet{},
+		},
+		{
+			name: "CNAME RecordSet",
+			hub: testRecordSet(v1alpha1.RecordConfig{
+				CName: &testCNAME,
+			}, v1alpha1.RecordTypeCName),
+			spoke: &RecordSet{},
+		},
+		{
+			name: "NS RecordSet",
+			hub: testRecordSet(v1alpha1.RecordConfig{
+				NS: []string{"ns1.example.com.", "ns2.example.com."},
+			}, v1alpha1.RecordTypeNS),
+			spoke: &RecordSet{},
+		},
+		{
+			name: "MX RecordSet",
+			hub: testRecordSet(v1alpha1.RecordConfig{
+				MX: []v1alpha1.MX{{Priority: 10, Host: "mail.example.com."}},
+			}, v1alpha1.RecordTypeMX),
+			spoke: &RecordSet{},
+		},
+		{
+			name: "SRV RecordSet",
+			hub: testRecordSet(v1alpha1.RecordConfig{
+				SRV: []v1alpha1.SRV{{Priority: 10, Weight: 5, Port: 5060, Host: "sip.example.com."}},
+			}, v1alpha1.RecordTypeSRV),
+			spoke: &RecordSet{},
+		},
+		{
+			name: "RecordSet with target references",
+			hub: testMultiRecordSet(
+				v1alpha1.RRset{RecordConfig: v1alpha1.RecordConfig{
+					CNameRef: &v1alpha1.TargetRef{
+						Kind: v1alpha1.TargetKindService, Name: "ingress", Namespace: "ingress"},
+				}},
+				v1alpha1.RRset{RecordConfig: v1alpha1.RecordConfig{
+					NSRefs: []v1alpha1.TargetRef{{Kind: v1alpha1.TargetKindRecordSet, Name: "ns1"}},
+				}},
+				v1alpha1.RRset{RecordConfig: v1alpha1.RecordConfig{
+					MX: []v1alpha1.MX{{Priority: 10, HostRef: &v1alpha1.TargetRef{
+						Kind: v1alpha1.TargetKindRecordSet, Name: "mail"}}},
+				}},
+				v1alpha1.RRset{RecordConfig: v1alpha1.RecordConfig{
+					SRV: []v1alpha1.SRV{{Priority: 10, Weight: 5, Port: 5060, HostRef: &v1alpha1.TargetRef{
+						Kind: v1alpha1.TargetKindService, Name: "sip"}}},
+				}},
+			),
+			spoke: &RecordSet{},
+		},
+		{
+			name: "ALIAS RecordSet",
+			hub: testMultiRecordSet(
+				v1alpha1.RRset{RecordConfig: v1alpha1.RecordConfig{Alias: &testAlias}},
+				v1alpha1.RRset{RecordConfig: v1alpha1.RecordConfig{TXT: []string{"v=spf1 -all"}}},
+			),
+			spoke: &RecordSet{},
+		},
+		{
+			name: "multi type RecordSet",
+			hub: testMultiRecordSet(
+				v1alpha1.RRset{RecordConfig: v1alpha1.RecordConfig{A: []string{"192.0.2.1"}}},
+				v1alpha1.RRset{
+					TTL:          &metav1.Duration{Duration: time.Hour},
+					RecordConfig: v1alpha1.RecordConfig{AAAA: []string{"2001:db8::1"}},
+				},
+				v1alpha1.RRset{RecordConfig: v1alpha1.RecordConfig{
+					MX: []v1alpha1.MX{{Priority: 10, Host: "mail.example.com."}},
+				}},
+			),
+			spoke: &RecordSet{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.spoke.ConvertFrom(test.hub); err != nil {
+				t.Fatalf("converting from hub: %v", err)
+			}
+			roundTripped := test.hub.DeepCopyObject().(conversion.Hub)
+			clearObject(t, roundTripped)
+			if err := test.spoke.ConvertTo(roundTripped); err != nil {
+				t.Fatalf("converting to hub: %v", err)
+			}
+			if diff := cmp.Diff(test.hub, roundTripped); diff != "" {
+				t.Errorf("round trip changed the object (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// v1alpha2 objects must survive a conversion to the hub and back.
+func TestConvertToHub(t *testing.T) {
+	tests := []struct {
+		name  string
+		spoke conversion.Convertible
+		hub   conversion.Hub
+	}{
+		{
+			name: "Zone",
+			spoke: &Zone{
+				ObjectMeta: metav1.ObjectMeta{Name: "example.com", Namespace: "default"},
+				Spec: ZoneSpec{
+					SOA:            SOARecord(testSOA),
+					AllowQuery:     []string{"192.0.2.0/24"},
+					DeletionPolicy: DeletionPolicyOrphan,
+				},
+				Status: ZoneStatus{
+					ServingAgents: []ServingAgent{{Name: "agent-1", Serial: 42, LastHeartbeat: testTime}},
+					InSync:        true,
+				},
+			},
+			hub: &v1alpha1.Zone{},
+		},
+		{
+			name: "ClusterZone",
+			spoke: &ClusterZone{
+				ObjectMeta: metav1.ObjectMeta{Name: "example.com"},
+				Spec:       ZoneSpec{SOA: SOARecord(testSOA)},
+			},
+			hub: &v1alpha1.ClusterZone{},
+		},
+		{
+			name: "RecordSet",
+			spoke: &RecordSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec: RecordSetSpec{
+					DNSName: "test",
+					ZoneRef: "example.com",
+					TTL:     metav1.Duration{Duration: time.Minute},
+					Records: Records{
+						MX: []MX{
+							{Priority: 10, Host: "mx1.example.com."},
+							{Priority: 20, Host: "mx2.example.com."},
+						},
+					},
+					MergePolicy: MergePolicyExclusive,
+				},
+				Status: RecordSetStatus{
+					Conditions: []RecordSetCondition{{
+						Type:               RecordSetConflict,
+						Status:             ConditionTrue,
+						LastTransitionTime: testTime,
+						Reason:             "TTLMismatch",
+					}},
+				},
+			},
+			hub: &v1alpha1.RecordSet{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.spoke.ConvertTo(test.hub); err != nil {
+				t.Fatalf("converting to hub: %v", err)
+			}
+			roundTripped := test.spoke.DeepCopyObject().(conversion.Convertible)
+			clearObject(t, roundTripped)
+			if err := roundTripped.ConvertFrom(test.hub); err != nil {
+				t.Fatalf("converting from hub: %v", err)
+			}
+			if diff := cmp.Diff(test.spoke, roundTripped); diff != "" {
+				t.Errorf("round trip changed the object (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConvertRecordSetType(t *testing.T) {
+	spoke := &RecordSet{
+		Spec: RecordSetSpec{Records: Records{AAAA: []string{"2001:db8::1"}}},
+	}
+	hub := &v1alpha1.RecordSet{}
+	if err := spoke.ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+	if hub.Record.Type != v1alpha1.RecordTypeAAAA {
+		t.Errorf("record type should be %q, is: %q", v1alpha1.RecordTypeAAAA, hub.Record.Type)
+	}
+}
+
+// clearObject resets obj to its zero value,
+// so a conversion cannot pass by leaving fields untouched.
+func clearObject(t *testing.T, obj runtime.Object) {
+	t.Helper()
+	switch o := obj.(type) {
+	case *v1alpha1.Zone:
+		*o = v1alpha1.Zone{}
+	case *v1alpha1.ClusterZone:
+		*o = v1alpha1.ClusterZone{}
+	case *v1alpha1.RecordSet:
+		*o = v1alpha1.RecordSet{}
+	case *Zone:
+		*o = Zone{}
+	case *ClusterZone:
+		*o = ClusterZone{}
+	case *RecordSet:
+		*o = RecordSet{}
+	default:
+		t.Fatalf("unexpected type %T", obj)
+	}
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RecordSet is the Schema for the recordsets API
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="DNS Name",type="string",JSONPath=".spec.dnsName"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type RecordSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RecordSetSpec   `json:"spec,omitempty"`
+	Status RecordSetStatus `json:"status,omitempty"`
+}
+
+// RecordSetSpec defines the desired state of a RecordSet.
+type RecordSetSpec struct {
+	// DNS_NAME that this record belongs to.
+	// must be fully qualified, unless ZoneRef is set.
+	// must belong to a existing Zone object.
+	DNSName string `json:"dnsName"`
+	// ZoneRef is the name of the Zone or ClusterZone DNSName is relative to.
+	// With a ZoneRef, DNSName is a relative name like "www" or "_dmarc",
+	// or "@" for the zone apex.
+	ZoneRef string `json:"zoneRef,omitempty"`
+	// TTL of the DNS entry.
+	TTL metav1.Duration `json:"ttl"`
+	// Records of the RecordSet, exactly one record type must be set,
+	// unless RRsets is used.
+	Records Records `json:"records,omitempty"`
+	// RRsets holds several typed RRsets for DNSName,
+	// instead of the single record type of Records.
+	RRsets []RRset `json:"rrsets,omitempty"`
+	// MergePolicy controls whether other RecordSets may contribute
+	// values to the same RRset. Defaults to Exclusive.
+	MergePolicy MergePolicy `json:"mergePolicy,omitempty"`
+	// MergeKey must match between all RecordSets sharing an RRset.
+	// Merge keys are scoped by namespace, RecordSets in other namespaces
+	// never share an RRset. Required for the Shared MergePolicy.
+	MergeKey string `json:"mergeKey,omitempty"`
+}
+
+// MergePolicy controls how RecordSets for the same name and type are combined.
+type MergePolicy string
+
+// MergePolicy values.
+const (
+	// The RecordSet is the only source of values for its RRset.
+	MergePolicyExclusive MergePolicy = "Exclusive"
+	// Values of all RecordSets with the same MergeKey are merged into one RRset.
+	MergePolicyShared MergePolicy = "Shared"
+)
+
+// Records is a union of the supported record types,
+// only one of its members may be set.
+type Records struct {
+	// A record, list of IPv4 addresses.
+	A []string `json:"a,omitempty"`
+	// AAAA record, list of IPv6 addresses.
+	AAAA []string `json:"aaaa,omitempty"`
+	// TXT record, list of strings.
+	// Values are taken literally, quotes and backslashes are escaped when served.
+	// Strings longer than 255 bytes are split into multiple segments
+	// of the same record.
+	TXT []string `json:"txt,omitempty"`
+	// CNAME record, Canonical Name of DNSName.
+	CName *string `json:"cname,omitempty"`
+	// CNAME record, pointing to the hostname of the referenced target.
+	// At the zone apex, or for targets without hostname, the addresses
+	// of the target are served as A and AAAA records instead.
+	CNameRef *TargetRef `json:"cnameRef,omitempty"`
+	// NS record, list of domain names.
+	NS []string `json:"ns,omitempty"`
+	// NS record, list of referenced targets.
+	NSRefs []TargetRef `json:"nsRefs,omitempty"`
+	// MX record, list of MX records.
+	MX []MX `json:"mx,omitempty"`
+	// SRV record, list of SRV records.
+	SRV []SRV `json:"srv,omitempty"`
+	// ALIAS record, hostname that is periodically resolved by the agent.
+	// Its addresses are served as A and AAAA records, so unlike a CNAME,
+	// an ALIAS can be used at the zone apex.
+	Alias *string `json:"alias,omitempty"`
+}
+
+// RRset holds the values of a single record type.
+type RRset struct {
+	// TTL of the RRset, defaults to the TTL of the RecordSet.
+	TTL     *metav1.Duration `json:"ttl,omitempty"`
+	Records `json:",inline"`
+}
+
+// MX mail server record.
+type MX struct {
+	Priority int    `json:"priority"`
+	Host     string `json:"host,omitempty"`
+	// HostRef references the target of the record instead of Host.
+	HostRef *TargetRef `json:"hostRef,omitempty"`
+}
+
+// SRV record.
+type SRV struct {
+	Priority int    `json:"priority"`
+	Weight   int    `json:"weight"`
+	Port     int    `json:"port"`
+	Host     string `json:"host,omitempty"`
+	// HostRef references the target of the record instead of Host.
+	HostRef *TargetRef `json:"hostRef,omitempty"`
+}
+
+// TargetRef references a RecordSet or Service, which is resolved
+// to its live hostname or addresses by the agent.
+type TargetRef struct {
+	// Kind of the target, RecordSet or Service.
+	Kind TargetKind `json:"kind"`
+	// Name of the target.
+	Name string `json:"name"`
+	// Namespace of the target, defaults to the namespace of the RecordSet.
+	// Targets in other namespaces must allow references from the namespace
+	// of the RecordSet with the allow-references-from annotation.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// TargetKind is the kind of object referenced by a TargetRef.
+type TargetKind string
+
+// TargetKind values.
+const (
+	// The owner name of the RecordSet, or its A and AAAA records.
+	TargetKindRecordSet TargetKind = "RecordSet"
+	// The external name or load balancer hostname of the Service,
+	// or its load balancer, external or cluster IPs.
+	TargetKindService TargetKind = "Service"
+)
+
+// RecordSetStatus represents the observed state of a RecordSet.
+type RecordSetStatus struct {
+	// Current service state of the RecordSet.
+	Conditions []RecordSetCondition `json:"conditions,omitempty"`
+}
+
+// RecordSetCondition contains details for the current condition of this RecordSet.
+type RecordSetCondition struct {
+	// Type is the type of the condition.
+	Type RecordSetConditionType `json:"type"`
+	// Status of the condition, one of True, False, Unknown.
+	Status ConditionStatus `json:"status"`
+	// Last time the condition transitioned from one status to another.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Unique, one-word, CamelCase reason for the condition's last transition.
+	Reason string `json:"reason,omitempty"`
+	// Human-readable message indicating details about last transition.
+	Message string `json:"message,omitempty"`
+}
+
+// RecordSetConditionType represents a RecordSet condition value.
+type RecordSetConditionType string
+
+const (
+	// RecordSetConflict is True when the RecordSet conflicts with
+	// another RecordSet and is not served.
+	RecordSetConflict RecordSetConditionType = "Conflict"
+	// RecordSetAliasResolved is False when agents failed to resolve
+	// the target of an ALIAS record.
+	RecordSetAliasResolved RecordSetConditionType = "AliasResolved"
+)
+
+// ConditionStatus represents a condition's status.
+type ConditionStatus string
+
+// ConditionStatus values.
+const (
+	ConditionTrue    ConditionStatus = "True"
+	ConditionFalse   ConditionStatus = "False"
+	ConditionUnknown ConditionStatus = "Unknown"
+)
+
+// RecordSetList contains a list of RecordSet
+// +kubebuilder:object:root=true
+type RecordSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RecordSet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RecordSet{}, &RecordSetList{})
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Zone is the Schema for the zones API
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Master",type="string",JSONPath=".spec.soa.master"
+// +kubebuilder:printcolumn:name="Admin",type="string",JSONPath=".spec.soa.admin"
+// +kubebuilder:printcolumn:name="Serial",type="integer",JSONPath=".spec.soa.serial"
+// +kubebuilder:printcolumn:name="In Sync",type="boolean",JSONPath=".status.inSync"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+type Zone struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ZoneSpec   `json:"spec,omitempty"`
+	Status ZoneStatus `json:"status,omitempty"`
+}
+
+// ZoneSpec defines the desired state of a Zone.
+type ZoneSpec struct {
+	// start of authority record
+	SOA SOARecord `json:"soa"`
+	// AllowQuery lists the client CIDRs allowed to query the zone,
+	// all other clients are REFUSED. Empty allows all clients.
+	AllowQuery []string `json:"allowQuery,omitempty"`
+	// DeletionPolicy decides what happens to the RecordSets of the zone
+	// when it is deleted, defaults to Block.
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// DeletionPolicy decides what happens to the RecordSets of a deleted zone.
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyBlock keeps the zone until all its RecordSets are deleted.
+	DeletionPolicyBlock DeletionPolicy = "Block"
+	// DeletionPolicyDelete deletes all RecordSets of the zone with it.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyOrphan keeps the RecordSets, which are no longer served.
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
+// SOARecord represents the SOA record for this zone.
+type SOARecord struct {
+	TTL         metav1.Duration `json:"ttl"`
+	Master      string          `json:"master"`
+	Admin       string          `json:"admin"`
+	Serial      int             `json:"serial"`
+	Refresh     metav1.Duration `json:"refresh"`
+	Retry       metav1.Duration `json:"retry"`
+	Expire      metav1.Duration `json:"expire"`
+	NegativeTTL metav1.Duration `json:"negativeTTL"`
+}
+
+// ZoneStatus reports which agents serve the Zone.
+type ZoneStatus struct {
+	// ServingAgents lists the agents with a current heartbeat serving this zone.
+	ServingAgents []ServingAgent `json:"servingAgents,omitempty"`
+	// InSync is true when at least one agent serves the zone
+	// and all serving agents serve the current serial.
+	InSync bool `json:"inSync"`
+}
+
+// ServingAgent is an agent serving a zone.
+type ServingAgent struct {
+	// Name of the agent, usually its Pod name.
+	Name string `json:"name"`
+	// Serial of the zone served by the agent.
+	Serial int `json:"serial"`
+	// LastHeartbeat is the time the agent last renewed its Lease.
+	LastHeartbeat metav1.Time `json:"lastHeartbeat"`
+}
+
+// ZoneList contains a list of Zone
+// +kubebuilder:object:root=true
+type ZoneList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Zone `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Zone{}, &ZoneList{})
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	route42plugin "github.com/thetechnick/route42/coredns"
+	"github.com/thetechnick/route42/coredns/manifests"
+)
+
+type digOptions struct {
+	Filenames []string
+	Client    string
+	TCP       bool
+	Short     bool
+	Verbose   bool
+}
+
+func newDigCommand() *cobra.Command {
+	opts := &digOptions{}
+	cmd := &cobra.Command{
+		Use:   "dig NAME [TYPE]",
+		Short: "Query zones built from manifests, without a cluster or network",
+		Long: `Dig loads Zones, ClusterZones, RecordSets and Services from manifests,
+builds the zones the same way as the route42 agent and answers the query
+in-process, printing the response like dig.
+TYPE defaults to A. ALIAS records are not resolved, as that needs network access.`,
+		Example: `  route42 dig -f config/samples www.example.com
+  route42 dig -f zones/ example.com MX --short`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return opts.Run(cmd.OutOrStdout(), args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringSliceVarP(&opts.Filenames, "filename", "f", nil,
+		"files or directories containing Zone and RecordSet manifests")
+	flags.StringVar(&opts.Client, "client", "127.0.0.1",
+		"address of the client sending the query, to check allowQuery")
+	flags.BoolVar(&opts.TCP, "tcp", false, "send the query over TCP")
+	flags.BoolVar(&opts.Short, "short", false, "only print the data of the answer records")
+	flags.BoolVarP(&opts.Verbose, "verbose", "v", false,
+		"log how the zones are built to stderr, e.g. unresolved targets")
+	_ = cmd.MarkFlagRequired("filename")
+	return cmd
+}
+
+func (o *digOptions) Run(out io.Writer, args []string) error {
+	qname := dns.Fqdn(args[0])
+	qtype := dns.TypeA
+	if len(args) > 1 {
+		t, ok := dns.StringToType[strings.ToUpper(args[1])]
+		if !ok {
+			return fmt.Errorf("unknown query type %s", args[1])
+		}
+		qtype = t
+	}
+	if net.ParseIP(o.Client) == nil {
+		return fmt.Errorf("--client must be an IP address, got %s", o.Client)
+	}
+	if o.Verbose {
+		ctrl.SetLogger(zap.Logger(true))
+	}
+
+	store := manifests.NewStore(o.Filenames...)
+	if err := store.Load(); err != nil {
+		return err
+	}
+	handler, update, err := route42plugin.NewStatic(store)
+	if err != nil {
+		return err
+	}
+	aliasKeys := make([]string, 0, len(update.AliasErrors))
+	for key := range update.AliasErrors {
+		aliasKeys = append(aliasKeys, key)
+	}
+	sort.Strings(aliasKeys)
+	for _, key := range aliasKeys {
+		fmt.Fprintf(os.Stderr, "RecordSet %s: ALIAS not served: %s\n", key, update.AliasErrors[key])
+	}
+
+	m := &dns.Msg{}
+	m.SetQuestion(qname, qtype)
+	rec := dnstest.NewRecorder(&digResponseWriter{client: net.ParseIP(o.Client), tcp: o.TCP})
+	start := time.Now()
+	rcode, err := handler.ServeDNS(context.Background(), rec, m)
+	elapsed := time.Since(start)
+
+	resp := rec.Msg
+	if resp == nil {
+		// like the CoreDNS server, write the error response
+		// the plugin left to it
+		if plugin.ClientWrite(rcode) {
+			return fmt.Errorf("no response: %v", err)
+		}
+		resp = &dns.Msg{}
+		resp.SetRcode(m, rcode)
+	}
+
+	if o.Short {
+		for _, rr := range resp.Answer {
+			fmt.Fprintln(out, strings.TrimPrefix(rr.String(), rr.Header().String()))
+		}
+		return nil
+	}
+
+	proto := "udp"
+	if o.TCP {
+		proto = "tcp"
+	}
+	fmt.Fprintf(out, "\n; <<>> route42 dig <<>> %s %s\n", qname, dns.TypeToString[qtype])
+	if err != nil {
+		fmt.Fprintf(out, ";; %v\n", err)
+	}
+	fmt.Fprintln(out, ";; Got answer:")
+	fmt.Fprintln(out, resp.String())
+	fmt.Fprintf(out, ";; Query time: %d msec\n", elapsed.Milliseconds())
+	fmt.Fprintf(out, ";; SERVER: route42 (in-process, %s from %s)\n", proto, o.Client)
+	fmt.Fprintf(out, ";; WHEN: %s\n", start.Format(time.UnixDate))
+	fmt.Fprintf(out, ";; MSG SIZE  rcvd: %d\n\n", resp.Len())
+	return nil
+}
+
+// digResponseWriter is the dns.ResponseWriter the in-process query is
+// answered through. The response is kept by the dnstest.Recorder wrapping
+// it, so writes are discarded.
+type digResponseWriter struct {
+	client net.IP
+	tcp    bool
+}
+
+var _ dns.ResponseWriter = (*digResponseWriter)(nil)
+
+func (w *digResponseWriter) LocalAddr() net.Addr {
+	return w.addr(net.IPv4(127, 0, 0, 1), 53)
+}
+
+func (w *digResponseWriter) RemoteAddr() net.Addr {
+	return w.addr(w.client, 40212)
+}
+
+func (w *digResponseWriter) addr(ip net.IP, port int) net.Addr {
+	if w.tcp {
+		return &net.TCPAddr{IP: ip, Port: port}
+	}
+	return &net.UDPAddr{IP: ip, Port: port}
+}
+
+func (w *digResponseWriter) WriteMsg(*dns.Msg) error { return nil }
+
+func (w *digResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+
+func (w *digResponseWriter) Close() error { return nil }
+
+func (w *digResponseWriter) TsigStatus() error { return nil }
+
+func (w *digResponseWriter) TsigTimersOnly(bool) {}
+
+func (w *digResponseWriter) Hijack() {}
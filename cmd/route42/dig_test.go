@@ -0,0 +1,108 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const digManifests = `apiVersion: route42.thetechnick.ninja/v1alpha1
+kind: Zone
+metadata:
+  name: example.com
+zone:
+  soa:
+    ttl: 1m
+    master: ns1.example.com.
+    admin: hostmaster.example.com.
+    serial: 1
+  allowQuery:
+  - 192.0.2.0/24
+---
+apiVersion: route42.thetechnick.ninja/v1alpha1
+kind: RecordSet
+metadata:
+  name: www
+record:
+  dnsName: www.example.com.
+  ttl: 5m
+  a:
+  - 192.0.2.1
+`
+
+func TestDig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "route42-dig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "manifests.yaml")
+	if err := ioutil.WriteFile(file, []byte(digManifests), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts digOptions
+		args []string
+		want []string
+	}{
+		{
+			name: "short",
+			opts: digOptions{Client: "192.0.2.10", Short: true},
+			args: []string{"www.example.com"},
+			want: []string{"192.0.2.1\n"},
+		},
+		{
+			name: "tcp",
+			opts: digOptions{Client: "192.0.2.10", TCP: true},
+			args: []string{"www.example.com", "a"},
+			want: []string{"status: NOERROR", "www.example.com.\t300\tIN\tA\t192.0.2.1",
+				"in-process, tcp from 192.0.2.10"},
+		},
+		{
+			name: "client refused by allowQuery",
+			opts: digOptions{Client: "198.51.100.1"},
+			args: []string{"www.example.com"},
+			want: []string{"status: REFUSED", "in-process, udp from 198.51.100.1"},
+		},
+		{
+			name: "IPv6 client",
+			opts: digOptions{Client: "2001:db8::1"},
+			args: []string{"www.example.com"},
+			want: []string{"status: REFUSED"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.opts.Filenames = []string{file}
+			var out strings.Builder
+			if err := test.opts.Run(&out, test.args); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("expected %q in the output:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
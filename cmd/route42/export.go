@@ -0,0 +1,135 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+
+	route42v1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
+	"github.com/thetechnick/route42/coredns/controllers"
+	"github.com/thetechnick/route42/coredns/manifests"
+)
+
+type exportOptions struct {
+	Filenames []string
+	Namespace string
+	OutputDir string
+}
+
+func newExportCommand() *cobra.Command {
+	opts := &exportOptions{}
+	cmd := &cobra.Command{
+		Use:   "export [ZONE...]",
+		Short: "Export zones as RFC 1035 master files",
+		Long: `Export renders Zones and ClusterZones with their RecordSets as RFC 1035
+master files, one file per zone named <zone>.zone.
+Objects are read from the cluster, unless --filename is given.
+All zones are exported, unless zone names are passed as arguments.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return opts.Run(args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringSliceVarP(&opts.Filenames, "filename", "f", nil,
+		"files or directories containing Zone and RecordSet manifests")
+	flags.StringVarP(&opts.Namespace, "namespace", "n", "",
+		"namespace to read Zones and RecordSets from, defaults to all namespaces")
+	flags.StringVarP(&opts.OutputDir, "output-dir", "o", ".",
+		`directory to write zone files to, "-" writes all zones to stdout`)
+	return cmd
+}
+
+func (o *exportOptions) Run(zoneNames []string) error {
+	var (
+		objs *manifests.Objects
+		err  error
+	)
+	if len(o.Filenames) > 0 {
+		objs, err = manifests.Load(o.Filenames...)
+	} else {
+		objs, err = loadCluster(context.Background(), o.Namespace)
+	}
+	if err != nil {
+		return err
+	}
+
+	zones := controllers.ZoneSources(objs.ClusterZones, objs.Zones)
+	sort.Slice(zones, func(i, j int) bool {
+		return zones[i].Name < zones[j].Name
+	})
+
+	wanted := map[string]bool{}
+	for _, name := range zoneNames {
+		wanted[strings.TrimSuffix(name, ".")] = true
+	}
+	// fail before writing any file, if a zone does not exist
+	found := map[string]bool{}
+	for _, zone := range zones {
+		found[zone.Name] = true
+	}
+	for name := range wanted {
+		if !found[name] {
+			return fmt.Errorf("zone %s not found", name)
+		}
+	}
+
+	conflicts := route42v1alpha1.FindConflicts(objs.RecordSets)
+	targets := controllers.NewTargets(objs.RecordSets, conflicts, objs.Services)
+	for _, zone := range zones {
+		if len(wanted) > 0 && !wanted[zone.Name] {
+			continue
+		}
+
+		records, err := controllers.ZoneRecords(zone.Name, objs.RecordSets, conflicts, targets)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "zone %s: skipping unresolved targets: %v\n", zone.Name, err)
+		}
+		rrs, err := controllers.RenderZone(zone, records)
+		if err != nil {
+			return fmt.Errorf("rendering zone %s: %w", zone.Name, err)
+		}
+		if err := o.write(zone.Name, rrs); err != nil {
+			return fmt.Errorf("writing zone %s: %w", zone.Name, err)
+		}
+	}
+	return nil
+}
+
+func (o *exportOptions) write(zoneName string, rrs []dns.RR) error {
+	if o.OutputDir == "-" {
+		return controllers.WriteZoneFile(os.Stdout, zoneName, rrs)
+	}
+
+	f, err := os.Create(filepath.Join(o.OutputDir, zoneName+".zone"))
+	if err != nil {
+		return err
+	}
+	if err := controllers.WriteZoneFile(f, zoneName, rrs); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const exportManifests = `apiVersion: route42.thetechnick.ninja/v1alpha1
+kind: Zone
+metadata:
+  name: example.com
+zone:
+  soa:
+    ttl: 1m
+    master: ns1.example.com.
+    admin: hostmaster.example.com.
+    serial: 1
+---
+apiVersion: route42.thetechnick.ninja/v1alpha1
+kind: ClusterZone
+metadata:
+  name: example.org
+zone:
+  soa:
+    ttl: 1m
+    master: ns1.example.org.
+    admin: hostmaster.example.org.
+    serial: 2
+---
+apiVersion: route42.thetechnick.ninja/v1alpha2
+kind: RecordSet
+metadata:
+  name: www
+spec:
+  dnsName: www.example.com.
+  ttl: 5m
+  records:
+    a:
+    - 192.0.2.1
+---
+# loses the conflict with www
+apiVersion: route42.thetechnick.ninja/v1alpha2
+kind: RecordSet
+metadata:
+  name: www-2
+spec:
+  dnsName: www.example.com.
+  ttl: 5m
+  records:
+    a:
+    - 192.0.2.2
+---
+apiVersion: route42.thetechnick.ninja/v1alpha2
+kind: RecordSet
+metadata:
+  name: lb
+spec:
+  dnsName: lb.example.com.
+  ttl: 5m
+  records:
+    cnameRef:
+      kind: Service
+      name: lb
+---
+apiVersion: route42.thetechnick.ninja/v1alpha2
+kind: RecordSet
+metadata:
+  name: txt
+spec:
+  dnsName: txt.example.com.
+  ttl: 5m
+  records:
+    txt:
+    - say "hello"
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: lb
+spec:
+  type: LoadBalancer
+status:
+  loadBalancer:
+    ingress:
+    - hostname: lb.example.net
+`
+
+func TestExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	manifest := filepath.Join(dir, "manifests.yaml")
+	if err := ioutil.WriteFile(manifest, []byte(exportManifests), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	if err := os.Mkdir(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &exportOptions{Filenames: []string{manifest}, OutputDir: out}
+	if err := opts.Run([]string{"example.com."}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(out, "example.com.zone"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// conflicted RecordSets are left out and targets are resolved
+	want := "$ORIGIN example.com.\n" +
+		"example.com.\t60\tIN\tSOA\tns1.example.com. hostmaster.example.com. 1 86400 7200 3600000 172800\n" +
+		"lb.example.com.\t300\tIN\tCNAME\tlb.example.net.\n" +
+		"txt.example.com.\t300\tIN\tTXT\t\"say \\\"hello\\\"\"\n" +
+		"www.example.com.\t300\tIN\tA\t192.0.2.1\n"
+	if string(b) != want {
+		t.Errorf("got zone file:\n%s\nwant:\n%s", b, want)
+	}
+	if _, err := os.Stat(filepath.Join(out, "example.org.zone")); !os.IsNotExist(err) {
+		t.Errorf("expected only the given zones to be exported, got %v", err)
+	}
+
+	opts.OutputDir = filepath.Join(dir, "all")
+	if err := os.Mkdir(opts.OutputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.Run(nil); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(opts.OutputDir, "*.zone"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("expected all zones to be exported, got %v", files)
+	}
+
+	if err := opts.Run([]string{"example.net"}); err == nil {
+		t.Error("expected an error for a missing zone")
+	}
+}
@@ -0,0 +1,403 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+
+	route42v1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
+)
+
+type importOptions struct {
+	Origin    string
+	Namespace string
+	OutputDir string
+}
+
+func newImportCommand() *cobra.Command {
+	opts := &importOptions{}
+	cmd := &cobra.Command{
+		Use:   "import FILE...",
+		Short: "Convert RFC 1035 master files into Zone and RecordSet manifests",
+		Long: `Import parses RFC 1035 master files, including $ORIGIN, $TTL and $INCLUDE
+directives, and converts them into a Zone and one RecordSet per owner name and type.
+The origin defaults to the file name without a ".zone" or ".db" extension
+and without a "db." prefix.
+Records that can not be represented by a RecordSet are reported and skipped.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return opts.Run(args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.Origin, "origin", "",
+		"origin of the zone, only valid when importing a single file")
+	flags.StringVarP(&opts.Namespace, "namespace", "n", "",
+		"namespace to set on the generated objects")
+	flags.StringVarP(&opts.OutputDir, "output-dir", "o", "-",
+		`directory to write <zone>.yaml files to, "-" writes all manifests to stdout`)
+	return cmd
+}
+
+func (o *importOptions) Run(files []string) error {
+	if o.Origin != "" && len(files) > 1 {
+		return fmt.Errorf("--origin can only be used with a single file")
+	}
+
+	for _, file := range files {
+		origin := o.Origin
+		if origin == "" {
+			origin = originFromFileName(file)
+		}
+
+		objs, err := o.importFile(file, dns.Fqdn(origin))
+		if err != nil {
+			return fmt.Errorf("importing %s: %w", file, err)
+		}
+		if err := o.write(origin, objs); err != nil {
+			return fmt.Errorf("writing %s: %w", origin, err)
+		}
+	}
+	return nil
+}
+
+// originFromFileName guesses the zone origin from common file naming schemes,
+// like example.com.zone or db.example.com.
+func originFromFileName(file string) string {
+	name := filepath.Base(file)
+	name = strings.TrimSuffix(name, ".zone")
+	name = strings.TrimSuffix(name, ".db")
+	name = strings.TrimPrefix(name, "db.")
+	return name
+}
+
+// rrsetKey identifies an RRset within a zone.
+type rrsetKey struct {
+	Name string
+	Type uint16
+}
+
+func (o *importOptions) importFile(file, origin string) ([]runtime.Object, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		zone    *route42v1alpha1.Zone
+		keys    []rrsetKey
+		records = map[rrsetKey]*route42v1alpha1.RecordSet{}
+		names   = map[string]dns.RR{}
+	)
+	zp := dns.NewZoneParser(f, origin, file)
+	zp.SetIncludeAllowed(true)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		hdr := rr.Header()
+		if soa, ok := rr.(*dns.SOA); ok {
+			if !strings.EqualFold(hdr.Name, origin) {
+				o.warn(file, rr, "SOA record outside of the zone apex")
+				continue
+			}
+			zone = o.zone(origin, soa)
+			continue
+		}
+
+		key := rrsetKey{Name: strings.ToLower(hdr.Name), Type: hdr.Rrtype}
+		recordSet, ok := records[key]
+		if !ok {
+			if recordSet, err = o.recordSet(hdr); err != nil {
+				o.warn(file, rr, err.Error())
+				continue
+			}
+		}
+		if err := addRecord(&recordSet.Record, rr); err != nil {
+			o.warn(file, rr, err.Error())
+			continue
+		}
+		if ttl := time.Duration(hdr.Ttl) * time.Second; ttl != recordSet.Record.TTL.Duration {
+			if ttl < recordSet.Record.TTL.Duration {
+				recordSet.Record.TTL.Duration = ttl
+			}
+			o.warn(file, rr, "TTL differs within RRset, using the lowest TTL")
+		}
+		if !ok {
+			if first, exists := names[recordSet.Name]; exists {
+				return nil, fmt.Errorf(
+					"RecordSets of %s and %s would both be named %s",
+					first.String(), rr.String(), recordSet.Name)
+			}
+			names[recordSet.Name] = rr
+			records[key] = recordSet
+			keys = append(keys, key)
+		}
+	}
+	if err := zp.Err(); err != nil {
+		return nil, err
+	}
+
+	var objs []runtime.Object
+	if zone != nil {
+		objs = append(objs, zone)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s: no SOA record for %s, skipping Zone\n", file, origin)
+	}
+	for _, key := range keys {
+		objs = append(objs, records[key])
+	}
+	return objs, nil
+}
+
+func (o *importOptions) zone(origin string, soa *dns.SOA) *route42v1alpha1.Zone {
+	return &route42v1alpha1.Zone{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: route42v1alpha1.GroupVersion.String(),
+			Kind:       "Zone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      strings.TrimSuffix(origin, "."),
+			Namespace: o.Namespace,
+		},
+		Zone: route42v1alpha1.ZoneConfig{
+			SOA: route42v1alpha1.SOARecord{
+				TTL:         seconds(soa.Hdr.Ttl),
+				Master:      soa.Ns,
+				Admin:       soa.Mbox,
+				Serial:      int(soa.Serial),
+				Refresh:     seconds(soa.Refresh),
+				Retry:       seconds(soa.Retry),
+				Expire:      seconds(soa.Expire),
+				NegativeTTL: seconds(soa.Minttl),
+			},
+		},
+	}
+}
+
+func (o *importOptions) recordSet(hdr *dns.RR_Header) (*route42v1alpha1.RecordSet, error) {
+	name, err := recordSetName(hdr)
+	if err != nil {
+		return nil, err
+	}
+	return &route42v1alpha1.RecordSet{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: route42v1alpha1.GroupVersion.String(),
+			Kind:       "RecordSet",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: o.Namespace,
+		},
+		Record: route42v1alpha1.Record{
+			DNSName: hdr.Name,
+			TTL:     seconds(hdr.Ttl),
+		},
+	}, nil
+}
+
+// encodedLabelPrefix marks owner name labels that are not valid
+// in object names and are encoded instead.
+const encodedLabelPrefix = "x--"
+
+// hashedLabelPrefix marks encoded labels that are too long for an object
+// name label and are replaced by their hash. Encoded labels never contain
+// "--" after the prefix, so hashed labels never match an encoded one.
+const hashedLabelPrefix = encodedLabelPrefix + "hash--"
+
+// recordSetName builds an object name from the record type and owner name,
+// e.g. "a.www.example.com".
+// Labels that are not valid in object names are encoded, so different
+// owner names never share an object name: "*" becomes "x--wildcard" and
+// other labels are prefixed with "x--", keeping letters and digits, and
+// replacing every other byte with "-" and its hex value,
+// e.g. "x---5fdmarc.example.com" for _dmarc.example.com.
+// Encoded labels longer than 63 characters are replaced by "x--hash--" and
+// the SHA-256 of the label. Names longer than 253 characters are rejected.
+func recordSetName(hdr *dns.RR_Header) (string, error) {
+	labels := []string{strings.ToLower(dns.TypeToString[hdr.Rrtype])}
+	for _, label := range dns.SplitDomainName(strings.ToLower(hdr.Name)) {
+		labels = append(labels, objectNameLabel(label))
+	}
+	name := strings.Join(labels, ".")
+	if len(name) > validation.DNS1123SubdomainMaxLength {
+		return "", fmt.Errorf(
+			"owner name too long for a RecordSet name, %s has %d characters, at most %d are allowed",
+			name, len(name), validation.DNS1123SubdomainMaxLength)
+	}
+	return name, nil
+}
+
+// objectNameLabel encodes the owner name label for an object name.
+func objectNameLabel(label string) string {
+	if label == "*" {
+		return encodedLabelPrefix + "wildcard"
+	}
+	if len(validation.IsDNS1123Label(label)) == 0 &&
+		!strings.HasPrefix(label, encodedLabelPrefix) {
+		return label
+	}
+
+	var b strings.Builder
+	b.WriteString(encodedLabelPrefix)
+	for i := 0; i < len(label); i++ {
+		if c := label[i]; (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "-%02x", label[i])
+	}
+	if b.Len() > validation.DNS1123LabelMaxLength {
+		sum := sha256.Sum256([]byte(label))
+		return hashedLabelPrefix + hex.EncodeToString(sum[:16])
+	}
+	return b.String()
+}
+
+var (
+	errUnsupportedRecord = errors.New("record type not supported by RecordSets")
+	errMultipleCNAMEs    = errors.New(
+		"more than one CNAME for the owner name, a name can only have a single CNAME")
+)
+
+// addRecord adds the value of rr to the record.
+// Returns an error if the record can not be represented.
+func addRecord(record *route42v1alpha1.Record, rr dns.RR) error {
+	switch rr := rr.(type) {
+	case *dns.A:
+		record.A = append(record.A, rr.A.String())
+	case *dns.AAAA:
+		record.AAAA = append(record.AAAA, rr.AAAA.String())
+	case *dns.TXT:
+		record.TXT = append(record.TXT, txtValue(rr))
+	case *dns.CNAME:
+		if record.CName != nil {
+			return errMultipleCNAMEs
+		}
+		target := rr.Target
+		record.CName = &target
+	case *dns.NS:
+		record.NS = append(record.NS, rr.Ns)
+	case *dns.MX:
+		record.MX = append(record.MX, route42v1alpha1.MX{
+			Priority: int(rr.Preference),
+			Host:     rr.Mx,
+		})
+	case *dns.SRV:
+		record.SRV = append(record.SRV, route42v1alpha1.SRV{
+			Priority: int(rr.Priority),
+			Weight:   int(rr.Weight),
+			Port:     int(rr.Port),
+			Host:     rr.Target,
+		})
+	default:
+		return errUnsupportedRecord
+	}
+	return nil
+}
+
+// txtValue joins the character-strings of a TXT record into the literal
+// value, without quotes and escapes of the presentation format,
+// as RecordSets split long values into segments themselves.
+func txtValue(rr *dns.TXT) string {
+	var b strings.Builder
+	for _, segment := range rr.Txt {
+		for i := 0; i < len(segment); i++ {
+			c := segment[i]
+			if c != '\\' || i+1 == len(segment) {
+				b.WriteByte(c)
+				continue
+			}
+			i++
+			if i+2 < len(segment) && isDigit(segment[i]) &&
+				isDigit(segment[i+1]) && isDigit(segment[i+2]) {
+				// \DDD is the decimal value of a byte
+				b.WriteByte((segment[i]-'0')*100 + (segment[i+1]-'0')*10 + segment[i+2] - '0')
+				i += 2
+				continue
+			}
+			b.WriteByte(segment[i])
+		}
+	}
+	return b.String()
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func seconds(s uint32) metav1.Duration {
+	return metav1.Duration{Duration: time.Duration(s) * time.Second}
+}
+
+func (o *importOptions) warn(file string, rr dns.RR, msg string) {
+	fmt.Fprintf(os.Stderr, "%s: %s: %s\n", file, msg, rr.String())
+}
+
+func (o *importOptions) write(origin string, objs []runtime.Object) error {
+	if o.OutputDir == "-" {
+		return writeManifests(os.Stdout, objs)
+	}
+
+	name := strings.TrimSuffix(origin, ".") + ".yaml"
+	f, err := os.Create(filepath.Join(o.OutputDir, name))
+	if err != nil {
+		return err
+	}
+	if err := writeManifests(f, objs); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeManifests writes the objects as multi document YAML,
+// omitting empty metadata and status fields.
+func writeManifests(w io.Writer, objs []runtime.Object) error {
+	for _, obj := range objs {
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return err
+		}
+		unstructured.RemoveNestedField(u, "metadata", "creationTimestamp")
+		unstructured.RemoveNestedField(u, "status")
+
+		b, err := yaml.Marshal(u)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "---\n%s", b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+
+	route42v1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
+)
+
+func TestTXTValue(t *testing.T) {
+	tests := []struct {
+		rr   string
+		want string
+	}{
+		{`example.com. 60 IN TXT "v=spf1 -all"`, "v=spf1 -all"},
+		// segments are joined, RecordSets split long values themselves
+		{`example.com. 60 IN TXT "v=DKIM1; " "p=MIGf"`, "v=DKIM1; p=MIGf"},
+		{`example.com. 60 IN TXT "a\"b\\c;d"`, `a"b\c;d`},
+		{`example.com. 60 IN TXT "tab\009end"`, "tab\tend"},
+	}
+	for _, test := range tests {
+		rr, err := dns.NewRR(test.rr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		record := &route42v1alpha1.Record{}
+		if err := addRecord(record, rr); err != nil {
+			t.Fatalf("%s: %v", test.rr, err)
+		}
+		if want := []string{test.want}; !reflect.DeepEqual(record.TXT, want) {
+			t.Errorf("%s: got %q, want %q", test.rr, record.TXT, want)
+		}
+	}
+}
+
+func TestRecordSetName(t *testing.T) {
+	long := strings.Repeat("a", 63)
+	tests := []struct {
+		name    string
+		rtype   uint16
+		want    string
+		wantErr bool
+	}{
+		{"www.example.com.", dns.TypeA, "a.www.example.com", false},
+		{"WWW.Example.com.", dns.TypeAAAA, "aaaa.www.example.com", false},
+		{"_dmarc.example.com.", dns.TypeTXT, "txt.x---5fdmarc.example.com", false},
+		{"dmarc.example.com.", dns.TypeTXT, "txt.dmarc.example.com", false},
+		{"_sip._tcp.example.com.", dns.TypeSRV, "srv.x---5fsip.x---5ftcp.example.com", false},
+		{"*.example.com.", dns.TypeA, "a.x--wildcard.example.com", false},
+		{"wildcard.example.com.", dns.TypeA, "a.wildcard.example.com", false},
+		// labels looking encoded are encoded themselves
+		{"x---5fdmarc.example.com.", dns.TypeTXT, "txt.x--x-2d-2d-2d5fdmarc.example.com", false},
+		{"-a.example.com.", dns.TypeA, "a.x---2da.example.com", false},
+		// encoded labels longer than 63 characters are hashed
+		{"_" + long[:57] + ".example.com.", dns.TypeTXT, "txt.x---5f" + long[:57] + ".example.com", false},
+		{"_" + long[:58] + ".example.com.", dns.TypeTXT,
+			"txt.x--hash--68280f778286ee63bf906ccdae6a828f.example.com", false},
+		{long + ".example.com.", dns.TypeA, "a." + long + ".example.com", false},
+		// a 253 character owner name is too long with the type prepended
+		{strings.Repeat(long+".", 3) + long[:49] + ".example.com.", dns.TypeA, "", true},
+	}
+	for _, test := range tests {
+		hdr := &dns.RR_Header{Name: test.name, Rrtype: test.rtype}
+		got, err := recordSetName(hdr)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s %s: got error %v, want error %v",
+				test.name, dns.TypeToString[test.rtype], err, test.wantErr)
+		}
+		if got != test.want {
+			t.Errorf("%s %s: got %s, want %s",
+				test.name, dns.TypeToString[test.rtype], got, test.want)
+		}
+		if len(got) > 0 {
+			for _, label := range strings.Split(got, ".") {
+				if len(label) > 63 {
+					t.Errorf("%s: label %s longer than 63 characters", got, label)
+				}
+			}
+		}
+	}
+}
+
+func TestAddRecord(t *testing.T) {
+	tests := []struct {
+		rrs  []string
+		want route42v1alpha1.RecordConfig
+		// errors of the records that are not added
+		errs []error
+	}{
+		{
+			rrs: []string{
+				"www.example.com. 60 IN A 192.0.2.1",
+				"www.example.com. 60 IN A 192.0.2.2",
+			},
+			want: route42v1alpha1.RecordConfig{A: []string{"192.0.2.1", "192.0.2.2"}},
+		},
+		{
+			rrs:  []string{"www.example.com. 60 IN AAAA 2001:db8::1"},
+			want: route42v1alpha1.RecordConfig{AAAA: []string{"2001:db8::1"}},
+		},
+		{
+			// a CNAME RRset holds a single record
+			rrs: []string{
+				"www.example.com. 60 IN CNAME a.example.net.",
+				"www.example.com. 60 IN CNAME b.example.net.",
+			},
+			want: route42v1alpha1.RecordConfig{CName: strPtr("a.example.net.")},
+			errs: []error{errMultipleCNAMEs},
+		},
+		{
+			rrs:  []string{"example.com. 60 IN NS ns1.example.com."},
+			want: route42v1alpha1.RecordConfig{NS: []string{"ns1.example.com."}},
+		},
+		{
+			rrs: []string{"example.com. 60 IN MX 10 mail.example.com."},
+			want: route42v1alpha1.RecordConfig{MX: []route42v1alpha1.MX{
+				{Priority: 10, Host: "mail.example.com."},
+			}},
+		},
+		{
+			rrs: []string{"_sip._tcp.example.com. 60 IN SRV 10 20 5060 sip.example.com."},
+			want: route42v1alpha1.RecordConfig{SRV: []route42v1alpha1.SRV{
+				{Priority: 10, Weight: 20, Port: 5060, Host: "sip.example.com."},
+			}},
+		},
+		{
+			rrs:  []string{"example.com. 60 IN CAA 0 issue \"ca.example.net\""},
+			errs: []error{errUnsupportedRecord},
+		},
+	}
+	for _, test := range tests {
+		record := &route42v1alpha1.Record{}
+		var errs []error
+		for _, s := range test.rrs {
+			rr, err := dns.NewRR(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := addRecord(record, rr); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if !reflect.DeepEqual(record.RecordConfig, test.want) {
+			t.Errorf("%v: got %+v, want %+v", test.rrs, record.RecordConfig, test.want)
+		}
+		if !reflect.DeepEqual(errs, test.errs) {
+			t.Errorf("%v: got errors %v, want %v", test.rrs, errs, test.errs)
+		}
+	}
+}
+
+func TestImportFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "route42-import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "db.example.com")
+	zone := `$TTL 300
+@       IN SOA ns1.example.com. admin.example.com. 7 7200 3600 1209600 300
+@       IN TXT "v=spf1 -all"
+_dmarc  IN TXT "v=DMARC1; p=none"
+dmarc   IN TXT "unrelated"
+www  60 IN A 192.0.2.1
+www  30 IN A 192.0.2.2
+`
+	if err := ioutil.WriteFile(file, []byte(zone), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o := &importOptions{Namespace: "dns"}
+	objs, err := o.importFile(file, "example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, obj := range objs {
+		switch obj := obj.(type) {
+		case *route42v1alpha1.Zone:
+			if obj.Zone.SOA.Serial != 7 {
+				t.Errorf("got serial %d, want 7", obj.Zone.SOA.Serial)
+			}
+			names = append(names, "zone/"+obj.Name)
+		case *route42v1alpha1.RecordSet:
+			if obj.Namespace != "dns" {
+				t.Errorf("%s: got namespace %q", obj.Name, obj.Namespace)
+			}
+			if obj.Name == "a.www.example.com" && obj.Record.TTL.Duration.Seconds() != 30 {
+				t.Errorf("expected the lowest TTL of the RRset, got %s", obj.Record.TTL.Duration)
+			}
+			names = append(names, obj.Name)
+		}
+	}
+	want := []string{
+		"zone/example.com",
+		"txt.example.com",
+		"txt.x---5fdmarc.example.com",
+		"txt.dmarc.example.com",
+		"a.www.example.com",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got objects %v, want %v", names, want)
+	}
+
+	var out strings.Builder
+	if err := writeManifests(&out, objs); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "- v=DMARC1; p=none\n") {
+		t.Errorf("expected the literal TXT value in the manifests:\n%s", out.String())
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
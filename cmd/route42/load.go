@@ -0,0 +1,69 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	route42v1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
+	"github.com/thetechnick/route42/coredns/manifests"
+)
+
+// loadCluster reads Route42 objects and Services from the cluster.
+// An empty namespace reads Zones, RecordSets and Services from all namespaces.
+func loadCluster(ctx context.Context, namespace string) (*manifests.Objects, error) {
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("creating config: %w", err)
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("creating client: %w", err)
+	}
+
+	objs := &manifests.Objects{}
+	clusterZoneList := &route42v1alpha1.ClusterZoneList{}
+	if err := c.List(ctx, clusterZoneList); err != nil && !meta.IsNoMatchError(err) {
+		return nil, fmt.Errorf("listing ClusterZones: %w", err)
+	}
+	objs.ClusterZones = clusterZoneList.Items
+
+	zoneList := &route42v1alpha1.ZoneList{}
+	if err := c.List(ctx, zoneList, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("listing Zones: %w", err)
+	}
+	objs.Zones = zoneList.Items
+
+	recordSetList := &route42v1alpha1.RecordSetList{}
+	if err := c.List(ctx, recordSetList, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("listing RecordSets: %w", err)
+	}
+	objs.RecordSets = recordSetList.Items
+
+	serviceList := &corev1.ServiceList{}
+	if err := c.List(ctx, serviceList, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("listing Services: %w", err)
+	}
+	objs.Services = serviceList.Items
+	return objs, nil
+}
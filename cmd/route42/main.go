@@ -0,0 +1,54 @@
+/*
+Copyright 2019 The Route42 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// route42 is the command line tool to work with Route42 objects.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+
+	route42v1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = route42v1alpha1.AddToScheme(scheme)
+}
+
+func main() {
+	rootCmd := &cobra.Command{
+		Use:          "route42",
+		Short:        "route42 manages DNS zones stored as Kubernetes objects",
+		SilenceUsage: true,
+	}
+	rootCmd.AddCommand(
+		newDigCommand(),
+		newExportCommand(),
+		newImportCommand(),
+	)
+
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,102 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+
+	dnsv1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
+)
+
+// AgentLeaseLister lists the heartbeat Leases of all agents.
+type AgentLeaseLister interface {
+	ListAgentLeases() ([]coordinationv1.Lease, error)
+}
+
+// AgentLeases caches the heartbeat Leases of all agents.
+// Only Leases with the agent label are watched, so the manager neither
+// caches all Leases of the cluster, nor lists them for every reconcile.
+type AgentLeases struct {
+	informer toolscache.SharedIndexInformer
+}
+
+var _ AgentLeaseLister = (*AgentLeases)(nil)
+
+// NewAgentLeases creates the cache, which must be added to the manager.
+func NewAgentLeases(cfg *rest.Config) (*AgentLeases, error) {
+	cs, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating clientset: %w", err)
+	}
+	selector := labels.SelectorFromSet(labels.Set{dnsv1alpha1.AgentLabel: "true"}).String()
+	leases := cs.CoordinationV1().Leases(metav1.NamespaceAll)
+	lw := &toolscache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			opts.LabelSelector = selector
+			return leases.List(opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			opts.LabelSelector = selector
+			return leases.Watch(opts)
+		},
+	}
+	return &AgentLeases{
+		informer: toolscache.NewSharedIndexInformer(
+			lw, &coordinationv1.Lease{}, 0, toolscache.Indexers{}),
+	}, nil
+}
+
+// Start watches the agent Leases until stop is closed.
+// Implements manager.Runnable.
+func (l *AgentLeases) Start(stop <-chan struct{}) error {
+	go l.informer.Run(stop)
+	if !toolscache.WaitForCacheSync(stop, l.informer.HasSynced) {
+		return fmt.Errorf("waiting for agent Leases to sync")
+	}
+	<-stop
+	return nil
+}
+
+// ListAgentLeases returns the cached agent Leases, sorted by name.
+func (l *AgentLeases) ListAgentLeases() ([]coordinationv1.Lease, error) {
+	if !l.informer.HasSynced() {
+		return nil, fmt.Errorf("agent Leases not synced yet")
+	}
+	objs := l.informer.GetStore().List()
+	leases := make([]coordinationv1.Lease, 0, len(objs))
+	for _, obj := range objs {
+		if lease, ok := obj.(*coordinationv1.Lease); ok {
+			leases = append(leases, *lease)
+		}
+	}
+	sort.Slice(leases, func(i, j int) bool {
+		if leases[i].Namespace != leases[j].Namespace {
+			return leases[i].Namespace < leases[j].Namespace
+		}
+		return leases[i].Name < leases[j].Name
+	})
+	return leases, nil
+}
@@ -0,0 +1,47 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	zoneCount = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "route42_zones",
+		Help: "Number of Zones per namespace, ClusterZones are reported with an empty namespace.",
+	}, []string{"namespace"})
+
+	recordSetCount = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "route42_recordsets",
+		Help: "Number of RecordSets per namespace.",
+	}, []string{"namespace"})
+
+	conflictCount = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "route42_recordset_conflicts",
+		Help: "Number of conflicted RecordSets per namespace and reason.",
+	}, []string{"namespace", "reason"})
+
+	validationRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "route42_validation_rejections_total",
+		Help: "Number of objects rejected by the validating webhooks, per kind and reason.",
+	}, []string{"kind", "reason"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(zoneCount, recordSetCount, conflictCount, validationRejections)
+}
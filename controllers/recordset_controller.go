@@ -17,34 +17,190 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	dnsv1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
 )
 
+// allRecordSets is the single request all RecordSet events are mapped to,
+// as conflicts can only be found by checking all RecordSets at once.
+// Events queued while a pass is running are collapsed into one more pass.
+var allRecordSets = types.NamespacedName{Name: "all-recordsets"}
+
 // RecordSetReconciler reconciles a RecordSet object
 type RecordSetReconciler struct {
 	client.Client
-	Log logr.Logger
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	// Leases lists the agent heartbeats.
+	Leases AgentLeaseLister
 }
 
 // +kubebuilder:rbac:groups=route42.thetechnick.ninja,resources=recordsets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=route42.thetechnick.ninja,resources=recordsets/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
+// Reconcile checks all RecordSets for conflicts and reports them via the
+// Conflict condition, as one change may resolve or cause conflicts elsewhere.
+// Every request is handled the same, see SetupWithManager.
+// ALIAS records additionally get the AliasResolved condition from the
+// errors reported in the agent heartbeats.
 func (r *RecordSetReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
-	_ = context.Background()
-	_ = r.Log.WithValues("recordset", req.NamespacedName)
+	ctx := context.Background()
+
+	recordSetList := &dnsv1alpha1.RecordSetList{}
+	if err := r.List(ctx, recordSetList); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	conflicts := dnsv1alpha1.FindConflicts(recordSetList.Items)
+	updateRecordSetMetrics(recordSetList.Items, conflicts)
+	aliasErrors, err := r.aliasErrors(ctx, recordSetList.Items)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	for i := range recordSetList.Items {
+		recordSet := &recordSetList.Items[i]
+		key := types.NamespacedName{Name: recordSet.Name, Namespace: recordSet.Namespace}
+		prev, hadCondition := recordSet.Status.GetCondition(dnsv1alpha1.RecordSetConflict)
+
+		cond := dnsv1alpha1.RecordSetCondition{
+			Type:   dnsv1alpha1.RecordSetConflict,
+			Status: dnsv1alpha1.ConditionFalse,
+			Reason: "NoConflict",
+		}
+		if c, ok := conflicts[key]; ok {
+			cond.Status = dnsv1alpha1.ConditionTrue
+			cond.Reason = c.Reason
+			cond.Message = c.Message
+		}
+		conflictChanged := recordSet.Status.SetCondition(cond)
+
+		var aliasCond *dnsv1alpha1.RecordSetCondition
+		if recordSet.Record.HasType(dnsv1alpha1.RecordTypeAlias) {
+			aliasCond = &dnsv1alpha1.RecordSetCondition{
+				Type:   dnsv1alpha1.RecordSetAliasResolved,
+				Status: dnsv1alpha1.ConditionTrue,
+				Reason: "Resolved",
+			}
+			if msg, ok := aliasErrors[key.String()]; ok {
+				aliasCond.Status = dnsv1alpha1.ConditionFalse
+				aliasCond.Reason = "ResolutionFailed"
+				aliasCond.Message = msg
+			}
+		}
+		aliasChanged := aliasCond != nil && recordSet.Status.SetCondition(*aliasCond)
+		if !conflictChanged && !aliasChanged {
+			continue
+		}
+
+		r.Log.Info("updating conditions", "recordset", key,
+			"status", cond.Status, "reason", cond.Reason)
+		if err := r.Status().Update(ctx, recordSet); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if aliasChanged && aliasCond.Status == dnsv1alpha1.ConditionFalse {
+			r.Recorder.Event(recordSet, corev1.EventTypeWarning, "AliasResolutionFailed", aliasCond.Message)
+		}
+		if !conflictChanged {
+			continue
+		}
+		switch {
+		case cond.Status == dnsv1alpha1.ConditionTrue:
+			r.Recorder.Event(recordSet, corev1.EventTypeWarning, "Conflicted", cond.Message)
+		case hadCondition && prev.Status == dnsv1alpha1.ConditionTrue:
+			r.Recorder.Event(recordSet, corev1.EventTypeNormal, "ConflictResolved",
+				"RecordSet no longer conflicts and is served")
+		case !hadCondition:
+			r.Recorder.Event(recordSet, corev1.EventTypeNormal, "Accepted",
+				"RecordSet accepted without conflicts")
+		}
+	}
 
-	// your logic here
+	return ctrl.Result{RequeueAfter: statusResyncInterval}, nil
+}
+
+// aliasErrors collects the ALIAS errors reported by all live agents,
+// keyed by namespace/name of the RecordSet.
+// The Leases are only read if any of the RecordSets is an ALIAS.
+func (r *RecordSetReconciler) aliasErrors(
+	ctx context.Context, recordSets []dnsv1alpha1.RecordSet,
+) (map[string]string, error) {
+	var hasAlias bool
+	for _, recordSet := range recordSets {
+		hasAlias = hasAlias || recordSet.Record.HasType(dnsv1alpha1.RecordTypeAlias)
+	}
+	if !hasAlias {
+		return nil, nil
+	}
+
+	leases, err := r.Leases.ListAgentLeases()
+	if err != nil {
+		return nil, fmt.Errorf("listing agent heartbeats: %w", err)
+	}
+
+	errs := map[string]string{}
+	now := time.Now()
+	for i := range leases {
+		lease := &leases[i]
+		if dnsv1alpha1.IsLeaseExpired(lease, now) {
+			continue
+		}
+		agentErrs, err := dnsv1alpha1.GetAgentAliasErrors(lease)
+		if err != nil {
+			r.Log.Error(err, "invalid agent heartbeat", "lease", lease.Namespace+"/"+lease.Name)
+			continue
+		}
+
+		name := lease.Name
+		if lease.Spec.HolderIdentity != nil {
+			name = *lease.Spec.HolderIdentity
+		}
+		for key, msg := range agentErrs {
+			if _, ok := errs[key]; !ok {
+				errs[key] = fmt.Sprintf("agent %s: %s", name, msg)
+			}
+		}
+	}
+	return errs, nil
+}
 
-	return ctrl.Result{}, nil
+func updateRecordSetMetrics(
+	recordSets []dnsv1alpha1.RecordSet, conflicts map[types.NamespacedName]dnsv1alpha1.Conflict) {
+	recordSetCount.Reset()
+	for _, recordSet := range recordSets {
+		recordSetCount.WithLabelValues(recordSet.Namespace).Inc()
+	}
+	conflictCount.Reset()
+	for key, c := range conflicts {
+		conflictCount.WithLabelValues(key.Namespace, c.Reason).Inc()
+	}
 }
 
+// SetupWithManager maps the events of all RecordSets to allRecordSets,
+// so every change is handled by a single pass over all RecordSets.
 func (r *RecordSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&dnsv1alpha1.RecordSet{}).
-		Complete(r)
+	c, err := controller.New("recordset", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+	return c.Watch(&source.Kind{Type: &dnsv1alpha1.RecordSet{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(handler.MapObject) []reconcile.Request {
+			return []reconcile.Request{{NamespacedName: allRecordSets}}
+		}),
+	})
 }
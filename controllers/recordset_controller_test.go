@@ -0,0 +1,87 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	dnsv1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
+)
+
+func TestRecordSetConditions(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = dnsv1alpha1.AddToScheme(scheme)
+
+	older := testRecordSet("older", "www.example.com.")
+	older.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+	newer := testRecordSet("newer", "www.example.com.")
+	newer.CreationTimestamp = metav1.NewTime(time.Now())
+	other := testRecordSet("other", "mail.example.com.")
+	c := fake.NewFakeClientWithScheme(scheme, older, newer, other)
+
+	recorder := record.NewFakeRecorder(10)
+	r := &RecordSetReconciler{Client: c, Log: log.NullLogger{}, Recorder: recorder}
+	// a single request checks all RecordSets
+	if _, err := r.Reconcile(ctrl.Request{NamespacedName: allRecordSets}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	tests := []struct {
+		recordSet *dnsv1alpha1.RecordSet
+		status    dnsv1alpha1.ConditionStatus
+	}{
+		{recordSet: older, status: dnsv1alpha1.ConditionFalse},
+		{recordSet: newer, status: dnsv1alpha1.ConditionTrue},
+		{recordSet: other, status: dnsv1alpha1.ConditionFalse},
+	}
+	for _, test := range tests {
+		recordSet := &dnsv1alpha1.RecordSet{}
+		if err := c.Get(ctx, key(test.recordSet), recordSet); err != nil {
+			t.Fatal(err)
+		}
+		cond, ok := recordSet.Status.GetCondition(dnsv1alpha1.RecordSetConflict)
+		if !ok || cond.Status != test.status {
+			t.Errorf("%s: got Conflict condition %+v, want status %s",
+				recordSet.Name, cond, test.status)
+		}
+	}
+	if n := len(recorder.Events); n != 3 {
+		t.Errorf("got %d Events, want one per RecordSet", n)
+	}
+
+	// unchanged conditions are neither updated nor reported again
+	for len(recorder.Events) > 0 {
+		<-recorder.Events
+	}
+	if _, err := r.Reconcile(ctrl.Request{NamespacedName: allRecordSets}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(recorder.Events); n != 0 {
+		t.Errorf("got %d Events for unchanged conditions", n)
+	}
+}
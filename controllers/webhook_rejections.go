@@ -0,0 +1,55 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
+
+	dnsv1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
+)
+
+// WebhookRejections counts the objects rejected by the validating webhooks
+// and emits Rejected Events on them.
+type WebhookRejections struct {
+	Recorder record.EventRecorder
+}
+
+var _ dnsv1alpha1.RejectionRecorder = (*WebhookRejections)(nil)
+
+// RecordRejection counts the rejection once per reason.
+// Objects rejected on creation were never stored,
+// so they get no Event that could not be attached to anything.
+func (w *WebhookRejections) RecordRejection(
+	obj runtime.Object, kind string, allErrs field.ErrorList) {
+	reasons := map[field.ErrorType]struct{}{}
+	for _, err := range allErrs {
+		if _, ok := reasons[err.Type]; ok {
+			continue
+		}
+		reasons[err.Type] = struct{}{}
+		validationRejections.WithLabelValues(kind, string(err.Type)).Inc()
+	}
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil || accessor.GetUID() == "" {
+		return
+	}
+	w.Recorder.Event(obj, corev1.EventTypeWarning, "Rejected", allErrs.ToAggregate().Error())
+}
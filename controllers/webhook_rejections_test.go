@@ -0,0 +1,88 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
+
+	dnsv1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
+)
+
+func TestWebhookRejections(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	dnsv1alpha1.SetRejectionRecorder(&WebhookRejections{Recorder: recorder})
+	defer dnsv1alpha1.SetRejectionRecorder(nil)
+
+	rejections := validationRejections.WithLabelValues("RecordSet", string(field.ErrorTypeInvalid))
+	valid := testRecordSet("www", "www.example.com.")
+	valid.UID = types.UID("2d5b6a8e-0b51-4c8a-9d53-1f1bb0e3c6f7")
+	valid.Default()
+	invalid := valid.DeepCopy()
+	invalid.Record.A = []string{"192.0.2.1", "not-an-address", "also-not-an-address"}
+	created := invalid.DeepCopy()
+	created.UID = ""
+
+	tests := []struct {
+		name     string
+		validate func() error
+		rejected bool
+		event    bool
+	}{
+		{name: "admitted", validate: func() error { return valid.ValidateUpdate(valid) }},
+		{
+			name:     "rejected update",
+			validate: func() error { return invalid.ValidateUpdate(valid) },
+			rejected: true,
+			event:    true,
+		},
+		{
+			// the object does not exist to attach an Event to
+			name:     "rejected create",
+			validate: created.ValidateCreate,
+			rejected: true,
+		},
+	}
+	for _, test := range tests {
+		before := testutil.ToFloat64(rejections)
+		err := test.validate()
+		if (err != nil) != test.rejected {
+			t.Errorf("%s: got error %v, want rejected %v", test.name, err, test.rejected)
+		}
+
+		// counted once per reason, not per error
+		want := before
+		if test.rejected {
+			want++
+		}
+		if got := testutil.ToFloat64(rejections); got != want {
+			t.Errorf("%s: got %v rejections, want %v", test.name, got, want)
+		}
+
+		var events int
+		for len(recorder.Events) > 0 {
+			<-recorder.Events
+			events++
+		}
+		if (events == 1) != test.event || events > 1 {
+			t.Errorf("%s: got %d Events, want an Event %v", test.name, events, test.event)
+		}
+	}
+}
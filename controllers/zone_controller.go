@@ -17,34 +17,355 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/miekg/dns"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	dnsv1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
 )
 
+// statusResyncInterval is the interval Zone status is recomputed in,
+// to notice agents that stopped renewing their heartbeat.
+const statusResyncInterval = 30 * time.Second
+
 // ZoneReconciler reconciles a Zone object
 type ZoneReconciler struct {
 	client.Client
-	Log logr.Logger
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	// Leases lists the agent heartbeats.
+	Leases AgentLeaseLister
+
+	// serials of the Zones seen by this manager, to report serial changes.
+	serials   map[types.NamespacedName]int
+	serialsMu sync.Mutex
 }
 
 // +kubebuilder:rbac:groups=route42.thetechnick.ninja,resources=zones,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=route42.thetechnick.ninja,resources=zones/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=route42.thetechnick.ninja,resources=clusterzones,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=route42.thetechnick.ninja,resources=clusterzones/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
+// Reconcile reports Zone metrics, emits Events when a Zone or ClusterZone
+// is first accepted or its serial changes, and aggregates the agent
+// heartbeats into the Zone status.
+// Deleted zones are finalized by applying their DeletionPolicy.
+// Requests without a namespace refer to ClusterZones.
 func (r *ZoneReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
-	_ = context.Background()
-	_ = r.Log.WithValues("zone", req.NamespacedName)
+	ctx := context.Background()
+	log := r.Log.WithValues("zone", req.NamespacedName)
+
+	if err := r.updateMetrics(ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var (
+		obj    zoneObject
+		config dnsv1alpha1.ZoneConfig
+		status *dnsv1alpha1.ZoneStatus
+	)
+	if req.Namespace == "" {
+		clusterZone := &dnsv1alpha1.ClusterZone{}
+		if err := r.Get(ctx, req.NamespacedName, clusterZone); err != nil {
+			return ctrl.Result{}, r.forget(req.NamespacedName, err)
+		}
+		obj, config, status = clusterZone, clusterZone.Zone, &clusterZone.Status
+	} else {
+		zone := &dnsv1alpha1.Zone{}
+		if err := r.Get(ctx, req.NamespacedName, zone); err != nil {
+			return ctrl.Result{}, r.forget(req.NamespacedName, err)
+		}
+		obj, config, status = zone, zone.Zone, &zone.Status
+	}
+
+	if obj.GetDeletionTimestamp() != nil {
+		return r.finalize(ctx, log, obj, config.GetDeletionPolicy())
+	}
+	if !hasFinalizer(obj) {
+		obj.SetFinalizers(append(obj.GetFinalizers(), dnsv1alpha1.ZoneFinalizer))
+		if err := r.Update(ctx, obj); err != nil {
+			return ctrl.Result{}, fmt.Errorf("adding finalizer: %w", err)
+		}
+	}
+
+	serial := config.SOA.Serial
+	r.recordSerial(log, req.NamespacedName, obj, serial)
+
+	newStatus, err := r.zoneStatus(ctx, req.Name, serial)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !reflect.DeepEqual(*status, newStatus) {
+		*status = newStatus
+		log.Info("updating status",
+			"servingAgents", len(newStatus.ServingAgents), "inSync", newStatus.InSync)
+		if err := r.Status().Update(ctx, obj); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: statusResyncInterval}, nil
+}
 
-	// your logic here
+// splitByNamespace splits the RecordSets of a deleted zone into those
+// it may delete and those of other namespaces.
+// A Zone only deletes RecordSets of its own namespace, as whoever deleted
+// it may not be allowed to delete RecordSets anywhere else.
+// ClusterZones may delete RecordSets in all namespaces.
+func splitByNamespace(
+	zone metav1.Object, recordSets []dnsv1alpha1.RecordSet,
+) (deletable, foreign []dnsv1alpha1.RecordSet) {
+	for _, recordSet := range recordSets {
+		if zone.GetNamespace() == "" || recordSet.Namespace == zone.GetNamespace() {
+			deletable = append(deletable, recordSet)
+			continue
+		}
+		foreign = append(foreign, recordSet)
+	}
+	return deletable, foreign
+}
+
+// zoneObject is a Zone or ClusterZone.
+type zoneObject interface {
+	runtime.Object
+	metav1.Object
+}
+
+// finalize applies the DeletionPolicy of a deleted zone to the RecordSets
+// only served by it, and removes the finalizer when done.
+func (r *ZoneReconciler) finalize(
+	ctx context.Context, log logr.Logger, obj zoneObject, policy dnsv1alpha1.DeletionPolicy,
+) (ctrl.Result, error) {
+	if !hasFinalizer(obj) {
+		return ctrl.Result{}, nil
+	}
 
+	recordSets, err := dnsv1alpha1.ExclusiveRecordSets(ctx, r.Client, obj)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	switch policy {
+	case dnsv1alpha1.DeletionPolicyBlock:
+		if len(recordSets) > 0 {
+			log.Info("deletion blocked", "recordSets", len(recordSets))
+			r.Recorder.Eventf(obj, corev1.EventTypeWarning, "DeletionBlocked",
+				"Zone still has %d RecordSets, delete them to finish deleting the zone", len(recordSets))
+			// retried when a RecordSet of the zone changes
+			return ctrl.Result{}, nil
+		}
+
+	case dnsv1alpha1.DeletionPolicyDelete:
+		deletable, foreign := splitByNamespace(obj, recordSets)
+		for i := range deletable {
+			recordSet := &deletable[i]
+			if err := r.Delete(ctx, recordSet); client.IgnoreNotFound(err) != nil {
+				return ctrl.Result{}, fmt.Errorf(
+					"deleting RecordSet %s/%s: %w", recordSet.Namespace, recordSet.Name, err)
+			}
+		}
+		if len(deletable) > 0 {
+			log.Info("deleted RecordSets", "recordSets", len(deletable))
+			r.Recorder.Eventf(obj, corev1.EventTypeNormal, "RecordSetsDeleted",
+				"Deleted %d RecordSets with the zone", len(deletable))
+		}
+		if len(foreign) > 0 {
+			log.Info("orphaned RecordSets of other namespaces", "recordSets", len(foreign))
+			r.Recorder.Eventf(obj, corev1.EventTypeWarning, "RecordSetsOrphaned",
+				"%d RecordSets in other namespaces are not deleted and no longer served", len(foreign))
+		}
+
+	case dnsv1alpha1.DeletionPolicyOrphan:
+		if len(recordSets) > 0 {
+			log.Info("orphaned RecordSets", "recordSets", len(recordSets))
+			r.Recorder.Eventf(obj, corev1.EventTypeWarning, "RecordSetsOrphaned",
+				"%d RecordSets are no longer served", len(recordSets))
+		}
+	}
+
+	var finalizers []string
+	for _, f := range obj.GetFinalizers() {
+		if f != dnsv1alpha1.ZoneFinalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	obj.SetFinalizers(finalizers)
+	if err := r.Update(ctx, obj); err != nil {
+		return ctrl.Result{}, fmt.Errorf("removing finalizer: %w", err)
+	}
 	return ctrl.Result{}, nil
 }
 
+func hasFinalizer(obj metav1.Object) bool {
+	for _, f := range obj.GetFinalizers() {
+		if f == dnsv1alpha1.ZoneFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// recordSerial emits Events when a Zone is first seen or its serial changed.
+func (r *ZoneReconciler) recordSerial(
+	log logr.Logger, key types.NamespacedName, obj runtime.Object, serial int) {
+	r.serialsMu.Lock()
+	defer r.serialsMu.Unlock()
+	if r.serials == nil {
+		r.serials = map[types.NamespacedName]int{}
+	}
+	last, seen := r.serials[key]
+	r.serials[key] = serial
+	switch {
+	case !seen:
+		log.Info("accepted", "serial", serial)
+		r.Recorder.Eventf(obj, corev1.EventTypeNormal, "Accepted",
+			"Zone accepted with serial %d", serial)
+	case last != serial:
+		log.Info("serial changed", "from", last, "to", serial)
+		r.Recorder.Eventf(obj, corev1.EventTypeNormal, "SerialChanged",
+			"Serial changed from %d to %d", last, serial)
+	}
+}
+
+// zoneStatus builds the status of a zone from the heartbeats
+// of all agents that currently serve it.
+func (r *ZoneReconciler) zoneStatus(
+	ctx context.Context, zoneName string, serial int) (dnsv1alpha1.ZoneStatus, error) {
+	leases, err := r.Leases.ListAgentLeases()
+	if err != nil {
+		return dnsv1alpha1.ZoneStatus{}, fmt.Errorf("listing agent heartbeats: %w", err)
+	}
+
+	status := dnsv1alpha1.ZoneStatus{}
+	fqdn := strings.ToLower(dns.Fqdn(zoneName))
+	now := time.Now()
+	for i := range leases {
+		lease := &leases[i]
+		if dnsv1alpha1.IsLeaseExpired(lease, now) {
+			continue
+		}
+		zones, err := dnsv1alpha1.GetAgentZones(lease)
+		if err != nil {
+			r.Log.Error(err, "invalid agent heartbeat", "lease", lease.Namespace+"/"+lease.Name)
+			continue
+		}
+		agentSerial, ok := zones[fqdn]
+		if !ok {
+			continue
+		}
+
+		name := lease.Name
+		if lease.Spec.HolderIdentity != nil {
+			name = *lease.Spec.HolderIdentity
+		}
+		status.ServingAgents = append(status.ServingAgents, dnsv1alpha1.ServingAgent{
+			Name:          name,
+			Serial:        agentSerial,
+			LastHeartbeat: metav1.NewTime(lease.Spec.RenewTime.Time.Truncate(time.Second)),
+		})
+	}
+	sort.Slice(status.ServingAgents, func(i, j int) bool {
+		return status.ServingAgents[i].Name < status.ServingAgents[j].Name
+	})
+
+	status.InSync = len(status.ServingAgents) > 0
+	for _, agent := range status.ServingAgents {
+		if agent.Serial != serial {
+			status.InSync = false
+		}
+	}
+	return status, nil
+}
+
+// forget drops the serial of deleted Zones and returns all other errors.
+func (r *ZoneReconciler) forget(key types.NamespacedName, err error) error {
+	if err := client.IgnoreNotFound(err); err != nil {
+		return fmt.Errorf("getting zone: %w", err)
+	}
+	r.serialsMu.Lock()
+	defer r.serialsMu.Unlock()
+	delete(r.serials, key)
+	return nil
+}
+
+func (r *ZoneReconciler) updateMetrics(ctx context.Context) error {
+	zoneList := &dnsv1alpha1.ZoneList{}
+	if err := r.List(ctx, zoneList); err != nil {
+		return err
+	}
+	clusterZoneList := &dnsv1alpha1.ClusterZoneList{}
+	if err := r.List(ctx, clusterZoneList); err != nil {
+		return err
+	}
+
+	counts := map[string]int{"": len(clusterZoneList.Items)}
+	for _, zone := range zoneList.Items {
+		counts[zone.Namespace]++
+	}
+	zoneCount.Reset()
+	for namespace, count := range counts {
+		zoneCount.WithLabelValues(namespace).Set(float64(count))
+	}
+	return nil
+}
+
+// deletedZoneRequests maps a RecordSet to the deleted zones it belongs to,
+// so blocked zones are finalized as soon as their last RecordSet is gone.
+func (r *ZoneReconciler) deletedZoneRequests(obj handler.MapObject) []reconcile.Request {
+	recordSet, ok := obj.Object.(*dnsv1alpha1.RecordSet)
+	if !ok {
+		return nil
+	}
+
+	ctx := context.Background()
+	var requests []reconcile.Request
+	clusterZoneList := &dnsv1alpha1.ClusterZoneList{}
+	if err := r.List(ctx, clusterZoneList); err != nil {
+		r.Log.Error(err, "listing ClusterZones")
+		return nil
+	}
+	for _, zone := range clusterZoneList.Items {
+		if zone.DeletionTimestamp != nil && recordSet.Record.InZone(zone.Name) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: zone.Name}})
+		}
+	}
+	zoneList := &dnsv1alpha1.ZoneList{}
+	if err := r.List(ctx, zoneList); err != nil {
+		r.Log.Error(err, "listing Zones")
+		return nil
+	}
+	for _, zone := range zoneList.Items {
+		if zone.DeletionTimestamp != nil && recordSet.Record.InZone(zone.Name) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: zone.Name, Namespace: zone.Namespace}})
+		}
+	}
+	return requests
+}
+
 func (r *ZoneReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&dnsv1alpha1.Zone{}).
+		Watches(&source.Kind{Type: &dnsv1alpha1.ClusterZone{}}, &handler.EnqueueRequestForObject{}).
+		Watches(&source.Kind{Type: &dnsv1alpha1.RecordSet{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.deletedZoneRequests),
+		}).
 		Complete(r)
 }
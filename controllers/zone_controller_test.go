@@ -0,0 +1,405 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	dnsv1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
+)
+
+// startTestEnv starts a local API server with the Route42 CRDs and a
+// manager running the ZoneReconciler, skipping the test if the envtest
+// binaries are not installed.
+func startTestEnv(t *testing.T) (client.Client, *record.FakeRecorder, func()) {
+	t.Helper()
+
+	assets := os.Getenv("KUBEBUILDER_ASSETS")
+	if assets == "" {
+		assets = "/usr/local/kubebuilder/bin"
+	}
+	for _, bin := range []string{"etcd", "kube-apiserver"} {
+		if _, err := os.Stat(filepath.Join(assets, bin)); err != nil {
+			t.Skipf("envtest binaries not installed: %v", err)
+		}
+	}
+
+	env := &envtest.Environment{
+		CRDDirectoryPaths: []string{filepath.Join("..", "config", "crd", "bases")},
+	}
+	cfg, err := env.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = dnsv1alpha1.AddToScheme(scheme)
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:             scheme,
+		MetricsBindAddress: "0",
+	})
+	if err != nil {
+		_ = env.Stop()
+		t.Fatal(err)
+	}
+
+	leases, err := NewAgentLeases(cfg)
+	if err == nil {
+		err = mgr.Add(leases)
+	}
+	if err != nil {
+		_ = env.Stop()
+		t.Fatal(err)
+	}
+	recorder := record.NewFakeRecorder(100)
+	if err := (&ZoneReconciler{
+		Client:   mgr.GetClient(),
+		Log:      log.NullLogger{},
+		Recorder: recorder,
+		Leases:   leases,
+	}).SetupWithManager(mgr); err != nil {
+		_ = env.Stop()
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := mgr.Start(stop); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		close(stop)
+		_ = env.Stop()
+		t.Fatal(err)
+	}
+	return c, recorder, func() {
+		close(stop)
+		<-done
+		_ = env.Stop()
+	}
+}
+
+func TestZoneDeletionPolicy(t *testing.T) {
+	c, recorder, stop := startTestEnv(t)
+	defer stop()
+
+	tests := []struct {
+		policy dnsv1alpha1.DeletionPolicy
+		// event expected after the zone was deleted
+		event string
+	}{
+		{policy: dnsv1alpha1.DeletionPolicyDelete, event: "RecordSetsDeleted"},
+		{policy: dnsv1alpha1.DeletionPolicyOrphan, event: "RecordSetsOrphaned"},
+		{policy: dnsv1alpha1.DeletionPolicyBlock, event: "DeletionBlocked"},
+	}
+	for _, test := range tests {
+		t.Run(string(test.policy), func(t *testing.T) {
+			ctx := context.Background()
+			zoneName := strings.ToLower(string(test.policy)) + ".example.com"
+			zone := testZone(zoneName, test.policy)
+			inZone := testRecordSet(zoneName+"-www", "www."+zoneName+".")
+			other := testRecordSet(zoneName+"-other", "www.example.org.")
+			for _, obj := range []runtime.Object{zone, inZone, other} {
+				if err := c.Create(ctx, obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			waitFor(t, "finalizer", func() (bool, error) {
+				if err := c.Get(ctx, key(zone), zone); err != nil {
+					return false, err
+				}
+				return hasFinalizer(zone), nil
+			})
+			if err := c.Delete(ctx, zone); err != nil {
+				t.Fatal(err)
+			}
+			waitForEvent(t, recorder, test.event)
+
+			if test.policy == dnsv1alpha1.DeletionPolicyBlock {
+				if err := c.Get(ctx, key(zone), zone); err != nil {
+					t.Fatalf("blocked zone is gone: %v", err)
+				}
+				if err := c.Delete(ctx, inZone); err != nil {
+					t.Fatal(err)
+				}
+			}
+			waitFor(t, "zone to be deleted", func() (bool, error) {
+				err := c.Get(ctx, key(zone), zone)
+				return apierrors.IsNotFound(err), client.IgnoreNotFound(err)
+			})
+
+			switch test.policy {
+			case dnsv1alpha1.DeletionPolicyDelete:
+				waitFor(t, "RecordSet to be deleted", func() (bool, error) {
+					err := c.Get(ctx, key(inZone), inZone)
+					return apierrors.IsNotFound(err), client.IgnoreNotFound(err)
+				})
+			case dnsv1alpha1.DeletionPolicyOrphan:
+				if err := c.Get(ctx, key(inZone), inZone); err != nil {
+					t.Errorf("orphaned RecordSet is gone: %v", err)
+				}
+			}
+			if err := c.Get(ctx, key(other), other); err != nil {
+				t.Errorf("RecordSet of another zone was touched: %v", err)
+			}
+		})
+	}
+}
+
+// TestFinalizeZone applies the DeletionPolicy of deleted zones with a fake
+// client, TestZoneDeletionPolicy covers the same against an API server.
+func TestFinalizeZone(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = dnsv1alpha1.AddToScheme(scheme)
+
+	tests := []struct {
+		policy dnsv1alpha1.DeletionPolicy
+		// without RecordSets in the zone
+		empty bool
+		event string
+		// the zone keeps its finalizer
+		blocked bool
+		// the RecordSet of the zone is deleted
+		deleted bool
+	}{
+		{policy: dnsv1alpha1.DeletionPolicyBlock, event: "DeletionBlocked", blocked: true},
+		{policy: dnsv1alpha1.DeletionPolicyBlock, empty: true},
+		{policy: dnsv1alpha1.DeletionPolicyDelete, event: "RecordSetsDeleted", deleted: true},
+		{policy: dnsv1alpha1.DeletionPolicyOrphan, event: "RecordSetsOrphaned"},
+		{policy: dnsv1alpha1.DeletionPolicyOrphan, empty: true},
+	}
+	for _, test := range tests {
+		name := string(test.policy)
+		if test.empty {
+			name += " without RecordSets"
+		}
+		t.Run(name, func(t *testing.T) {
+			now := metav1.Now()
+			zone := testZone("example.com", test.policy)
+			zone.Finalizers = []string{dnsv1alpha1.ZoneFinalizer}
+			zone.DeletionTimestamp = &now
+			inZone := testRecordSet("www", "www.example.com.")
+			other := testRecordSet("other", "www.example.org.")
+			objs := []runtime.Object{zone, other}
+			if !test.empty {
+				objs = append(objs, inZone)
+			}
+			c := fake.NewFakeClientWithScheme(scheme, objs...)
+
+			recorder := record.NewFakeRecorder(10)
+			r := &ZoneReconciler{Client: c, Log: log.NullLogger{}, Recorder: recorder}
+			if _, err := r.Reconcile(ctrl.Request{NamespacedName: key(zone)}); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			got := &dnsv1alpha1.Zone{}
+			if err := c.Get(ctx, key(zone), got); err != nil {
+				t.Fatal(err)
+			}
+			if hasFinalizer(got) != test.blocked {
+				t.Errorf("got finalizer %v, want %v", hasFinalizer(got), test.blocked)
+			}
+			if !test.empty {
+				err := c.Get(ctx, key(inZone), inZone)
+				if deleted := apierrors.IsNotFound(err); deleted != test.deleted {
+					t.Errorf("got RecordSet deleted %v, want %v: %v", deleted, test.deleted, err)
+				}
+			}
+			if err := c.Get(ctx, key(other), other); err != nil {
+				t.Errorf("RecordSet of another zone was touched: %v", err)
+			}
+
+			var reasons []string
+			for len(recorder.Events) > 0 {
+				reasons = append(reasons, strings.Fields(<-recorder.Events)[1])
+			}
+			var want []string
+			if test.event != "" {
+				want = []string{test.event}
+			}
+			if !reflect.DeepEqual(reasons, want) {
+				t.Errorf("got Events %v, want %v", reasons, want)
+			}
+		})
+	}
+}
+
+func TestFinalizeOtherNamespaces(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = dnsv1alpha1.AddToScheme(scheme)
+
+	zone := testZone("example.com", dnsv1alpha1.DeletionPolicyDelete)
+	zone.Finalizers = []string{dnsv1alpha1.ZoneFinalizer}
+	own := testRecordSet("www", "www.example.com.")
+	foreign := testRecordSet("www", "shop.example.com.")
+	foreign.Namespace = "other"
+	c := fake.NewFakeClientWithScheme(scheme, zone, own, foreign)
+
+	recorder := record.NewFakeRecorder(10)
+	r := &ZoneReconciler{Client: c, Log: log.NullLogger{}, Recorder: recorder}
+	ctx := context.Background()
+	if _, err := r.finalize(ctx, r.Log, zone, dnsv1alpha1.DeletionPolicyDelete); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Get(ctx, key(own), own); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the RecordSet of the zone's namespace to be deleted, got %v", err)
+	}
+	if err := c.Get(ctx, key(foreign), foreign); err != nil {
+		t.Errorf("RecordSet of another namespace was deleted: %v", err)
+	}
+	waitForEvent(t, recorder, "RecordSetsOrphaned")
+}
+
+// staticLeases is an AgentLeaseLister returning fixed Leases.
+type staticLeases []coordinationv1.Lease
+
+func (l staticLeases) ListAgentLeases() ([]coordinationv1.Lease, error) {
+	return l, nil
+}
+
+func TestZoneStatus(t *testing.T) {
+	now := metav1.NewMicroTime(time.Now())
+	lease := func(name string, renewed metav1.MicroTime, zones dnsv1alpha1.AgentZones) coordinationv1.Lease {
+		duration := int32(40)
+		l := coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &name,
+				LeaseDurationSeconds: &duration,
+				RenewTime:            &renewed,
+			},
+		}
+		if err := dnsv1alpha1.SetAgentZones(&l, zones); err != nil {
+			t.Fatal(err)
+		}
+		return l
+	}
+	r := &ZoneReconciler{Log: log.NullLogger{}, Leases: staticLeases{
+		lease("agent-b", now, dnsv1alpha1.AgentZones{"example.com.": 1}),
+		lease("agent-a", now, dnsv1alpha1.AgentZones{"example.com.": 2, "example.org.": 2}),
+		// expired heartbeats are ignored
+		lease("agent-c", metav1.NewMicroTime(now.Add(-time.Hour)), dnsv1alpha1.AgentZones{"example.com.": 1}),
+	}}
+
+	status, err := r.zoneStatus(context.Background(), "example.com", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, agent := range status.ServingAgents {
+		names = append(names, agent.Name)
+	}
+	if want := []string{"agent-a", "agent-b"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got serving agents %v, want %v", names, want)
+	}
+	if status.InSync {
+		t.Error("expected not in sync, while agent-b serves an old serial")
+	}
+
+	status, err = r.zoneStatus(context.Background(), "example.org", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.InSync || len(status.ServingAgents) != 1 {
+		t.Errorf("expected example.org to be in sync on agent-a, got %+v", status)
+	}
+}
+
+func testZone(name string, policy dnsv1alpha1.DeletionPolicy) *dnsv1alpha1.Zone {
+	zone := &dnsv1alpha1.Zone{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+	}
+	zone.Zone.SOA = dnsv1alpha1.SOARecord{
+		TTL:    metav1.Duration{Duration: time.Minute},
+		Master: "ns." + name + ".",
+		Admin:  "hostmaster." + name + ".",
+		Serial: 1,
+	}
+	zone.Zone.Default()
+	zone.Zone.DeletionPolicy = policy
+	return zone
+}
+
+func testRecordSet(name, dnsName string) *dnsv1alpha1.RecordSet {
+	return &dnsv1alpha1.RecordSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Record: dnsv1alpha1.Record{
+			DNSName:      dnsName,
+			TTL:          metav1.Duration{Duration: time.Minute},
+			RecordConfig: dnsv1alpha1.RecordConfig{A: []string{"192.0.2.1"}},
+		},
+	}
+}
+
+func key(obj metav1.Object) types.NamespacedName {
+	return types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}
+}
+
+func waitFor(t *testing.T, what string, condition wait.ConditionFunc) {
+	t.Helper()
+	if err := wait.PollImmediate(100*time.Millisecond, 10*time.Second, condition); err != nil {
+		t.Fatalf("waiting for %s: %v", what, err)
+	}
+}
+
+// waitForEvent waits for an Event with the given reason,
+// dropping all Events before it.
+func waitForEvent(t *testing.T, recorder *record.FakeRecorder, reason string) {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case event := <-recorder.Events:
+			// FakeRecorder formats Events as "<type> <reason> <message>"
+			if fields := strings.Fields(event); len(fields) > 1 && fields[1] == reason {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no %s Event", reason)
+		}
+	}
+}
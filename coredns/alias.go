@@ -0,0 +1,326 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route42plugin
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/miekg/dns"
+
+	"github.com/thetechnick/route42/coredns/controllers"
+)
+
+const (
+	// aliasMinRefresh is the shortest interval a target is resolved in,
+	// even if its records have a lower TTL.
+	aliasMinRefresh = 5 * time.Second
+	// aliasRetryInterval is the interval failed targets are resolved again in.
+	aliasRetryInterval = 30 * time.Second
+	// defaultAliasRefresh is the default of aliasResolver.MaxRefresh.
+	defaultAliasRefresh = 5 * time.Minute
+)
+
+// aliasResolver periodically resolves the targets of ALIAS records
+// through upstream DNS servers.
+// Targets are resolved again when the TTL of their records expired.
+type aliasResolver struct {
+	// Servers to resolve targets with, as host:port.
+	Servers []string
+	// MaxRefresh is the longest interval a target is resolved in,
+	// even if its records have a higher TTL.
+	MaxRefresh time.Duration
+
+	client   *dns.Client
+	log      logr.Logger
+	onChange func()
+	now      func() time.Time
+
+	mu      sync.Mutex
+	targets map[string]*aliasEntry
+	wake    chan struct{}
+}
+
+var _ controllers.AliasResolver = (*aliasResolver)(nil)
+
+type aliasEntry struct {
+	addrs controllers.AliasTarget
+	err   error
+	// next is the time to resolve the target again.
+	next time.Time
+}
+
+func newAliasResolver(servers []string, log logr.Logger) *aliasResolver {
+	return &aliasResolver{
+		Servers:    servers,
+		MaxRefresh: defaultAliasRefresh,
+		client:     &dns.Client{Timeout: 2 * time.Second},
+		log:        log,
+		now:        time.Now,
+		targets:    map[string]*aliasEntry{},
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// OnChange registers a function that is called after the addresses
+// of a target, or the error resolving it changed.
+// Must be called before the resolver is started.
+func (a *aliasResolver) OnChange(fn func()) {
+	a.onChange = fn
+}
+
+// Lookup returns the addresses the target resolved to.
+// New targets are not resolved inline, as that would block building
+// all zones, but return controllers.ErrAliasPending and are resolved
+// by the refresh loop, which calls onChange once they resolved.
+func (a *aliasResolver) Lookup(target string) (controllers.AliasTarget, error) {
+	a.mu.Lock()
+	entry, ok := a.targets[target]
+	if !ok {
+		entry = &aliasEntry{err: controllers.ErrAliasPending, next: a.now()}
+		a.targets[target] = entry
+	}
+	a.mu.Unlock()
+	if !ok {
+		select {
+		case a.wake <- struct{}{}:
+		default:
+		}
+	}
+	return entry.addrs, entry.err
+}
+
+// Retain stops resolving all targets, but the given ones.
+func (a *aliasResolver) Retain(targets []string) {
+	keep := map[string]struct{}{}
+	for _, target := range targets {
+		keep[target] = struct{}{}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for target := range a.targets {
+		if _, ok := keep[target]; !ok {
+			delete(a.targets, target)
+		}
+	}
+}
+
+// Start refreshes the targets until stop is closed.
+// Implements manager.Runnable.
+func (a *aliasResolver) Start(stop <-chan struct{}) error {
+	timer := time.NewTimer(a.MaxRefresh)
+	defer timer.Stop()
+	for {
+		if a.refresh() && a.onChange != nil {
+			a.onChange()
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(a.nextRefresh())
+		select {
+		case <-stop:
+			return nil
+		case <-timer.C:
+		case <-a.wake:
+		}
+	}
+}
+
+// refresh resolves all targets that are due.
+// Returns true if the addresses of any target changed.
+func (a *aliasResolver) refresh() bool {
+	now := a.now()
+	due := map[string]*aliasEntry{}
+	a.mu.Lock()
+	for target, entry := range a.targets {
+		if !entry.next.After(now) {
+			due[target] = entry
+		}
+	}
+	a.mu.Unlock()
+
+	var changed bool
+	for target, prev := range due {
+		entry := a.resolve(target, prev)
+		if !reflect.DeepEqual(entry.addrs.A, prev.addrs.A) ||
+			!reflect.DeepEqual(entry.addrs.AAAA, prev.addrs.AAAA) ||
+			errString(entry.err) != errString(prev.err) {
+			changed = true
+		}
+
+		a.mu.Lock()
+		if _, ok := a.targets[target]; ok {
+			a.targets[target] = entry
+		}
+		a.mu.Unlock()
+	}
+	return changed
+}
+
+// nextRefresh returns the time until the next target is due.
+func (a *aliasResolver) nextRefresh() time.Duration {
+	next := a.MaxRefresh
+	now := a.now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, entry := range a.targets {
+		if d := entry.next.Sub(now); d < next {
+			next = d
+		}
+	}
+	if next < 0 {
+		next = 0
+	}
+	return next
+}
+
+// resolve looks up the A and AAAA records of the target.
+// On failure, the addresses of the previous entry are kept.
+func (a *aliasResolver) resolve(target string, prev *aliasEntry) *aliasEntry {
+	entry := &aliasEntry{}
+	addrs, err := a.lookup(target)
+	if err != nil {
+		a.log.Error(err, "resolving ALIAS target", "target", target)
+		entry.addrs = prev.addrs
+		entry.err = err
+		entry.next = a.now().Add(minDuration(aliasRetryInterval, a.MaxRefresh))
+		return entry
+	}
+
+	refresh := addrs.TTL
+	if refresh < aliasMinRefresh {
+		refresh = aliasMinRefresh
+	}
+	entry.addrs = addrs
+	entry.next = a.now().Add(minDuration(refresh, a.MaxRefresh))
+	return entry
+}
+
+// lookup queries the A and AAAA records of the target.
+// The TTL is the lowest TTL of all answers, including CNAMEs leading
+// to the addresses.
+func (a *aliasResolver) lookup(target string) (controllers.AliasTarget, error) {
+	var (
+		addrs  controllers.AliasTarget
+		minTTL uint32
+		first  = true
+	)
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		answer, err := a.exchange(target, qtype)
+		if err != nil {
+			return controllers.AliasTarget{}, err
+		}
+		for _, rr := range answer {
+			if ttl := rr.Header().Ttl; first || ttl < minTTL {
+				minTTL, first = ttl, false
+			}
+			switch rr := rr.(type) {
+			case *dns.A:
+				addrs.A = append(addrs.A, rr.A.String())
+			case *dns.AAAA:
+				addrs.AAAA = append(addrs.AAAA, rr.AAAA.String())
+			}
+		}
+	}
+	if len(addrs.A) == 0 && len(addrs.AAAA) == 0 {
+		return controllers.AliasTarget{}, fmt.Errorf("no A or AAAA records")
+	}
+	sort.Strings(addrs.A)
+	sort.Strings(addrs.AAAA)
+	addrs.TTL = time.Duration(minTTL) * time.Second
+	return addrs, nil
+}
+
+// exchange sends the query to the servers in order,
+// until one of them answers.
+func (a *aliasResolver) exchange(target string, qtype uint16) ([]dns.RR, error) {
+	if len(a.Servers) == 0 {
+		return nil, fmt.Errorf("no resolver configured")
+	}
+
+	m := &dns.Msg{}
+	m.SetQuestion(dns.Fqdn(target), qtype)
+	m.RecursionDesired = true
+
+	var err error
+	for _, server := range a.Servers {
+		var r *dns.Msg
+		if r, _, err = a.client.Exchange(m, server); err != nil {
+			continue
+		}
+		if r.Rcode != dns.RcodeSuccess {
+			return nil, fmt.Errorf("%s query: %s", dns.TypeToString[qtype], dns.RcodeToString[r.Rcode])
+		}
+		return r.Answer, nil
+	}
+	return nil, fmt.Errorf("%s query: %w", dns.TypeToString[qtype], err)
+}
+
+// parseAliasResolvers returns the given servers as host:port,
+// defaulting to port 53.
+func parseAliasResolvers(servers []string) ([]string, error) {
+	addrs := make([]string, len(servers))
+	for i, server := range servers {
+		if _, _, err := net.SplitHostPort(server); err == nil {
+			addrs[i] = server
+			continue
+		}
+		if net.ParseIP(server) == nil {
+			return nil, fmt.Errorf("alias_resolver must be an IP address with optional port, got '%s'", server)
+		}
+		addrs[i] = net.JoinHostPort(server, "53")
+	}
+	return addrs, nil
+}
+
+// systemResolvers returns the name servers from /etc/resolv.conf.
+func systemResolvers() ([]string, error) {
+	config, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+	if err != nil {
+		return nil, err
+	}
+	servers := make([]string, len(config.Servers))
+	for i, server := range config.Servers {
+		servers[i] = net.JoinHostPort(server, config.Port)
+	}
+	return servers, nil
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,263 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route42plugin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/thetechnick/route42/coredns/controllers"
+	"github.com/thetechnick/route42/coredns/manifests"
+)
+
+// aliasTestRecords are served by the stub resolver.
+var aliasTestRecords = map[string][]string{
+	"lb.example.net.": {
+		"lb.example.net. 30 IN A 198.51.100.2",
+		"lb.example.net. 30 IN A 198.51.100.1",
+		"lb.example.net. 20 IN AAAA 2001:db8::1",
+	},
+	"cdn.example.net.": {
+		"cdn.example.net. 3600 IN CNAME edge.example.net.",
+		"edge.example.net. 2 IN A 203.0.113.1",
+	},
+}
+
+// startStubResolver serves aliasTestRecords on a local UDP port and
+// answers NXDOMAIN for all other names.
+func startStubResolver(t *testing.T) (string, func()) {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	server := &dns.Server{
+		PacketConn:        pc,
+		NotifyStartedFunc: func() { close(started) },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			m := &dns.Msg{}
+			m.SetReply(r)
+			records, ok := aliasTestRecords[r.Question[0].Name]
+			if !ok {
+				m.Rcode = dns.RcodeNameError
+			}
+			for _, record := range records {
+				rr, err := dns.NewRR(record)
+				if err != nil {
+					panic(err)
+				}
+				if rr.Header().Rrtype == r.Question[0].Qtype ||
+					rr.Header().Rrtype == dns.TypeCNAME {
+					m.Answer = append(m.Answer, rr)
+				}
+			}
+			_ = w.WriteMsg(m)
+		}),
+	}
+	go func() { _ = server.ActivateAndServe() }()
+	<-started
+	return pc.LocalAddr().String(), func() { _ = server.Shutdown() }
+}
+
+func TestAliasResolver(t *testing.T) {
+	addr, stop := startStubResolver(t)
+	defer stop()
+
+	now := time.Date(2019, 11, 1, 0, 0, 0, 0, time.UTC)
+	resolver := newAliasResolver([]string{addr}, log.NullLogger{})
+	resolver.now = func() time.Time { return now }
+
+	tests := []struct {
+		target   string
+		want     controllers.AliasTarget
+		wantErr  bool
+		wantNext time.Duration
+	}{
+		{
+			target: "lb.example.net.",
+			want: controllers.AliasTarget{
+				A:    []string{"198.51.100.1", "198.51.100.2"},
+				AAAA: []string{"2001:db8::1"},
+				TTL:  20 * time.Second,
+			},
+			wantNext: 20 * time.Second,
+		},
+		{
+			// the lowest TTL of the chain, refreshed no faster than aliasMinRefresh
+			target: "cdn.example.net.",
+			want: controllers.AliasTarget{
+				A:   []string{"203.0.113.1"},
+				TTL: 2 * time.Second,
+			},
+			wantNext: aliasMinRefresh,
+		},
+		{
+			target:   "missing.example.net.",
+			wantErr:  true,
+			wantNext: aliasRetryInterval,
+		},
+	}
+	// new targets are resolved by the refresh loop, not by Lookup
+	for _, test := range tests {
+		if _, err := resolver.Lookup(test.target); !errors.Is(err, controllers.ErrAliasPending) {
+			t.Errorf("%s: expected a pending target, got %v", test.target, err)
+		}
+	}
+	if !resolver.refresh() {
+		t.Error("expected resolving pending targets to be a change")
+	}
+
+	for _, test := range tests {
+		t.Run(test.target, func(t *testing.T) {
+			got, err := resolver.Lookup(test.target)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+			if next := resolver.targets[test.target].next.Sub(now); next != test.wantNext {
+				t.Errorf("next refresh in %s, want %s", next, test.wantNext)
+			}
+		})
+	}
+
+	resolver.Retain([]string{"lb.example.net."})
+	if len(resolver.targets) != 1 {
+		t.Errorf("expected only the retained target, got %d", len(resolver.targets))
+	}
+}
+
+const aliasTestManifests = testManifests + `
+---
+apiVersion: route42.thetechnick.ninja/v1alpha1
+kind: RecordSet
+metadata:
+  name: apex
+  namespace: default
+record:
+  zoneRef: example.com
+  dnsName: "@"
+  ttl: 60s
+  alias: lb.example.net.
+---
+apiVersion: route42.thetechnick.ninja/v1alpha1
+kind: RecordSet
+metadata:
+  name: missing
+  namespace: default
+record:
+  dnsName: missing.example.com.
+  ttl: 60s
+  alias: missing.example.net.
+`
+
+func TestAliasRecords(t *testing.T) {
+	addr, stopResolver := startStubResolver(t)
+	defer stopResolver()
+
+	p, stop := newTestPlugin(t, aliasTestManifests, func(p *route42plugin) {
+		p.Aliases.Servers = []string{addr}
+		p.Aliases.log = log.NullLogger{}
+	})
+	defer stop()
+
+	tests := []struct {
+		qname string
+		qtype uint16
+		want  []string
+	}{
+		{
+			// the lowest TTL of the target, as it is below the TTL of the RecordSet
+			qname: "example.com.",
+			qtype: dns.TypeA,
+			want: []string{
+				"example.com.\t20\tIN\tA\t198.51.100.1",
+				"example.com.\t20\tIN\tA\t198.51.100.2",
+			},
+		},
+		{
+			qname: "example.com.",
+			qtype: dns.TypeAAAA,
+			want:  []string{"example.com.\t20\tIN\tAAAA\t2001:db8::1"},
+		},
+		{
+			qname: "missing.example.com.",
+			qtype: dns.TypeA,
+		},
+	}
+	answer := func(qname string, qtype uint16) []string {
+		m := &dns.Msg{}
+		m.SetQuestion(qname, qtype)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := p.ServeDNS(context.Background(), rec, m); err != nil {
+			t.Fatalf("ServeDNS: %v", err)
+		}
+		var got []string
+		for _, rr := range rec.Msg.Answer {
+			got = append(got, rr.String())
+		}
+		sort.Strings(got)
+		return got
+	}
+
+	// targets are resolved in the background, which rebuilds the zones
+	deadline := time.Now().Add(5 * time.Second)
+	for len(answer("example.com.", dns.TypeA)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("ALIAS target was not resolved in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, q := range tests {
+		if got := answer(q.qname, q.qtype); !reflect.DeepEqual(got, q.want) {
+			t.Errorf("%s %s: got %q, want %q",
+				q.qname, dns.TypeToString[q.qtype], got, q.want)
+		}
+	}
+
+	// the failed target is reported for the heartbeat
+	store := manifests.NewStore(p.Directory)
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	var update controllers.Update
+	r := controllers.NewZoneReconciler(store, log.NullLogger{})
+	r.SetAliasResolver(p.Aliases)
+	r.OnUpdate(func(u controllers.Update) { update = u })
+	if _, err := r.Reconcile(ctrl.Request{}); err != nil {
+		t.Fatal(err)
+	}
+	errs := update.AliasErrors
+	if _, ok := errs["default/missing"]; !ok || len(errs) != 1 {
+		t.Errorf("expected an error for default/missing, got %v", errs)
+	}
+}
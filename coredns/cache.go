@@ -0,0 +1,549 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route42plugin
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/watch"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// newCacheFunc returns a cache.NewCacheFunc that restricts namespaced objects
+// to the given namespaces and only lists and watches objects matching the
+// label selector registered for their GroupKind.
+// An empty namespace list watches all namespaces.
+func newCacheFunc(
+	namespaces []string, selectors map[schema.GroupKind]labels.Selector,
+) cache.NewCacheFunc {
+	return func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		var (
+			c   cache.Cache
+			err error
+		)
+		switch len(namespaces) {
+		case 0:
+			c, err = cache.New(config, opts)
+		case 1:
+			opts.Namespace = namespaces[0]
+			c, err = cache.New(config, opts)
+		default:
+			c, err = newScopedCache(config, opts, namespaces)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(selectors) == 0 {
+			return c, nil
+		}
+		return newSelectorCache(c, config, opts, namespaces, selectors)
+	}
+}
+
+// scopedCache sends namespaced objects to a cache watching multiple namespaces
+// and cluster-scoped objects to a single cluster-wide cache.
+// Without this, every namespace would run its own informer for
+// cluster-scoped objects and List would return duplicates.
+type scopedCache struct {
+	namespaced cache.Cache
+	cluster    cache.Cache
+
+	scheme *runtime.Scheme
+	mapper meta.RESTMapper
+}
+
+var _ cache.Cache = (*scopedCache)(nil)
+
+func newScopedCache(
+	config *rest.Config, opts cache.Options, namespaces []string,
+) (*scopedCache, error) {
+	namespaced, err := cache.MultiNamespacedCacheBuilder(namespaces)(config, opts)
+	if err != nil {
+		return nil, err
+	}
+	opts.Namespace = ""
+	cluster, err := cache.New(config, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &scopedCache{
+		namespaced: namespaced,
+		cluster:    cluster,
+		scheme:     opts.Scheme,
+		mapper:     opts.Mapper,
+	}, nil
+}
+
+func (c *scopedCache) cacheForKind(gvk schema.GroupVersionKind) (cache.Cache, error) {
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		return c.cluster, nil
+	}
+	return c.namespaced, nil
+}
+
+func (c *scopedCache) cacheFor(obj runtime.Object) (cache.Cache, error) {
+	gvk, err := apiutil.GVKForObject(obj, c.scheme)
+	if err != nil {
+		return nil, err
+	}
+	return c.cacheForKind(gvk)
+}
+
+func (c *scopedCache) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	cc, err := c.cacheFor(obj)
+	if err != nil {
+		return err
+	}
+	return cc.Get(ctx, key, obj)
+}
+
+func (c *scopedCache) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	cc, err := c.cacheFor(list)
+	if err != nil {
+		return err
+	}
+	return cc.List(ctx, list, opts...)
+}
+
+func (c *scopedCache) GetInformer(obj runtime.Object) (cache.Informer, error) {
+	cc, err := c.cacheFor(obj)
+	if err != nil {
+		return nil, err
+	}
+	return cc.GetInformer(obj)
+}
+
+func (c *scopedCache) GetInformerForKind(gvk schema.GroupVersionKind) (cache.Informer, error) {
+	cc, err := c.cacheForKind(gvk)
+	if err != nil {
+		return nil, err
+	}
+	return cc.GetInformerForKind(gvk)
+}
+
+func (c *scopedCache) Start(stopCh <-chan struct{}) error {
+	errCh := make(chan error, 2)
+	for _, cc := range []cache.Cache{c.namespaced, c.cluster} {
+		go func(cc cache.Cache) {
+			errCh <- cc.Start(stopCh)
+		}(cc)
+	}
+	select {
+	case err := <-errCh:
+		return err
+	case <-stopCh:
+		return nil
+	}
+}
+
+func (c *scopedCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	return c.namespaced.WaitForCacheSync(stop) &&
+		c.cluster.WaitForCacheSync(stop)
+}
+
+func (c *scopedCache) IndexField(obj runtime.Object, field string, extractValue client.IndexerFunc) error {
+	cc, err := c.cacheFor(obj)
+	if err != nil {
+		return err
+	}
+	return cc.IndexField(obj, field, extractValue)
+}
+
+// selectorCache serves the GroupKinds with a label selector from its own
+// informers, which send the selector to the API server with every list and
+// watch, so objects that do not match are never transferred or cached.
+// All other GroupKinds are served by the embedded cache.
+type selectorCache struct {
+	cache.Cache
+
+	config     *rest.Config
+	scheme     *runtime.Scheme
+	mapper     meta.RESTMapper
+	codecs     serializer.CodecFactory
+	paramCodec runtime.ParameterCodec
+	resync     time.Duration
+	namespaces []string
+	selectors  map[schema.GroupKind]labels.Selector
+
+	mu        sync.Mutex
+	informers map[schema.GroupVersionKind]*selectorInformer
+	// stop is set once the cache is started,
+	// informers created afterwards are started right away.
+	stop <-chan struct{}
+}
+
+var _ cache.Cache = (*selectorCache)(nil)
+
+func newSelectorCache(
+	c cache.Cache, config *rest.Config, opts cache.Options,
+	namespaces []string, selectors map[schema.GroupKind]labels.Selector,
+) (*selectorCache, error) {
+	if opts.Scheme == nil {
+		opts.Scheme = clientgoscheme.Scheme
+	}
+	if opts.Mapper == nil {
+		mapper, err := apiutil.NewDiscoveryRESTMapper(config)
+		if err != nil {
+			return nil, fmt.Errorf("creating RESTMapper: %w", err)
+		}
+		opts.Mapper = mapper
+	}
+	resync := 10 * time.Hour
+	if opts.Resync != nil {
+		resync = *opts.Resync
+	}
+	return &selectorCache{
+		Cache:      c,
+		config:     config,
+		scheme:     opts.Scheme,
+		mapper:     opts.Mapper,
+		codecs:     serializer.NewCodecFactory(opts.Scheme),
+		paramCodec: runtime.NewParameterCodec(opts.Scheme),
+		resync:     resync,
+		namespaces: namespaces,
+		selectors:  selectors,
+		informers:  map[schema.GroupVersionKind]*selectorInformer{},
+	}, nil
+}
+
+// gvkFor returns the GroupVersionKind of obj, with the List suffix of
+// list types removed.
+func (c *selectorCache) gvkFor(obj runtime.Object) (schema.GroupVersionKind, error) {
+	gvk, err := apiutil.GVKForObject(obj, c.scheme)
+	if err != nil {
+		return gvk, err
+	}
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	return gvk, nil
+}
+
+// informerFor returns the informer for the given GroupVersionKind, creating
+// it on first use, or nil if no label selector is registered for it.
+func (c *selectorCache) informerFor(gvk schema.GroupVersionKind) (*selectorInformer, error) {
+	selector, ok := c.selectors[gvk.GroupKind()]
+	if !ok {
+		return nil, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i, ok := c.informers[gvk]; ok {
+		return i, nil
+	}
+
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := c.scheme.New(gvk)
+	if err != nil {
+		return nil, err
+	}
+	// one informer per namespace, so only namespaced RBAC is needed
+	namespaces := []string{metav1.NamespaceAll}
+	if mapping.Scope.Name() != meta.RESTScopeNameRoot && len(c.namespaces) > 0 {
+		namespaces = c.namespaces
+	}
+	i := &selectorInformer{gvk: gvk}
+	for _, namespace := range namespaces {
+		lw, err := c.listWatch(mapping, gvk, namespace, selector)
+		if err != nil {
+			return nil, err
+		}
+		i.informers = append(i.informers, toolscache.NewSharedIndexInformer(
+			lw, obj, c.resync, toolscache.Indexers{
+				toolscache.NamespaceIndex: toolscache.MetaNamespaceIndexFunc,
+			}))
+	}
+	c.informers[gvk] = i
+	if c.stop != nil {
+		i.run(c.stop)
+	}
+	return i, nil
+}
+
+// listWatch lists and watches the objects of the given kind and namespace,
+// that match the label selector.
+func (c *selectorCache) listWatch(
+	mapping *meta.RESTMapping, gvk schema.GroupVersionKind,
+	namespace string, selector labels.Selector,
+) (*toolscache.ListWatch, error) {
+	client, err := apiutil.RESTClientForGVK(gvk, c.config, c.codecs)
+	if err != nil {
+		return nil, err
+	}
+	listObj, err := c.scheme.New(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err != nil {
+		return nil, err
+	}
+	return &toolscache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			opts.LabelSelector = selector.String()
+			res := listObj.DeepCopyObject()
+			err := client.Get().
+				NamespaceIfScoped(namespace, namespace != metav1.NamespaceAll).
+				Resource(mapping.Resource.Resource).
+				VersionedParams(&opts, c.paramCodec).
+				Do().Into(res)
+			return res, err
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			opts.LabelSelector = selector.String()
+			opts.Watch = true
+			return client.Get().
+				NamespaceIfScoped(namespace, namespace != metav1.NamespaceAll).
+				Resource(mapping.Resource.Resource).
+				VersionedParams(&opts, c.paramCodec).
+				Watch()
+		},
+	}, nil
+}
+
+func (c *selectorCache) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	gvk, err := c.gvkFor(obj)
+	if err != nil {
+		return err
+	}
+	i, err := c.informerFor(gvk)
+	if err != nil {
+		return err
+	}
+	if i == nil {
+		return c.Cache.Get(ctx, key, obj)
+	}
+
+	storeKey := key.Name
+	if key.Namespace != "" {
+		storeKey = key.Namespace + "/" + key.Name
+	}
+	for _, informer := range i.informers {
+		item, exists, err := informer.GetIndexer().GetByKey(storeKey)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return copyInto(item, obj, gvk)
+		}
+	}
+	return apierrors.NewNotFound(schema.GroupResource{
+		Group:    gvk.Group,
+		Resource: gvk.Kind,
+	}, key.Name)
+}
+
+func (c *selectorCache) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	gvk, err := c.gvkFor(list)
+	if err != nil {
+		return err
+	}
+	i, err := c.informerFor(gvk)
+	if err != nil {
+		return err
+	}
+	if i == nil {
+		return c.Cache.List(ctx, list, opts...)
+	}
+
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	if listOpts.FieldSelector != nil && !listOpts.FieldSelector.Empty() {
+		return fmt.Errorf("field selectors are not supported for %s with a label selector", gvk.Kind)
+	}
+
+	var items []runtime.Object
+	for _, informer := range i.informers {
+		var objs []interface{}
+		if listOpts.Namespace != "" {
+			objs, err = informer.GetIndexer().ByIndex(toolscache.NamespaceIndex, listOpts.Namespace)
+			if err != nil {
+				return err
+			}
+		} else {
+			objs = informer.GetIndexer().List()
+		}
+		for _, item := range objs {
+			obj, ok := item.(runtime.Object)
+			if !ok {
+				return fmt.Errorf("cache contained %T, which is not an Object", item)
+			}
+			if listOpts.LabelSelector != nil {
+				accessor, err := meta.Accessor(obj)
+				if err != nil {
+					return err
+				}
+				if !listOpts.LabelSelector.Matches(labels.Set(accessor.GetLabels())) {
+					continue
+				}
+			}
+			items = append(items, obj.DeepCopyObject())
+		}
+	}
+	return meta.SetList(list, items)
+}
+
+// copyInto copies the cached object into out.
+func copyInto(item interface{}, out runtime.Object, gvk schema.GroupVersionKind) error {
+	obj, ok := item.(runtime.Object)
+	if !ok {
+		return fmt.Errorf("cache contained %T, which is not an Object", item)
+	}
+	outVal := reflect.ValueOf(out)
+	objVal := reflect.ValueOf(obj.DeepCopyObject())
+	if !objVal.Type().AssignableTo(outVal.Type()) {
+		return fmt.Errorf("cache had type %s, but %s was asked for", objVal.Type(), outVal.Type())
+	}
+	reflect.Indirect(outVal).Set(reflect.Indirect(objVal))
+	out.GetObjectKind().SetGroupVersionKind(gvk)
+	return nil
+}
+
+func (c *selectorCache) GetInformer(obj runtime.Object) (cache.Informer, error) {
+	gvk, err := c.gvkFor(obj)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetInformerForKind(gvk)
+}
+
+func (c *selectorCache) GetInformerForKind(gvk schema.GroupVersionKind) (cache.Informer, error) {
+	i, err := c.informerFor(gvk)
+	if err != nil {
+		return nil, err
+	}
+	if i == nil {
+		return c.Cache.GetInformerForKind(gvk)
+	}
+	c.mu.Lock()
+	stop := c.stop
+	c.mu.Unlock()
+	if stop != nil && !i.HasSynced() {
+		// do not hand out informers of a running cache before they synced
+		if !toolscache.WaitForCacheSync(stop, i.HasSynced) {
+			return nil, fmt.Errorf("failed waiting for %s informer to sync", gvk.Kind)
+		}
+	}
+	return i, nil
+}
+
+func (c *selectorCache) Start(stopCh <-chan struct{}) error {
+	c.mu.Lock()
+	c.stop = stopCh
+	for _, i := range c.informers {
+		i.run(stopCh)
+	}
+	c.mu.Unlock()
+	return c.Cache.Start(stopCh)
+}
+
+func (c *selectorCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	c.mu.Lock()
+	var synced []toolscache.InformerSynced
+	for _, i := range c.informers {
+		synced = append(synced, i.HasSynced)
+	}
+	c.mu.Unlock()
+	return toolscache.WaitForCacheSync(stop, synced...) &&
+		c.Cache.WaitForCacheSync(stop)
+}
+
+func (c *selectorCache) IndexField(obj runtime.Object, field string, extractValue client.IndexerFunc) error {
+	gvk, err := c.gvkFor(obj)
+	if err != nil {
+		return err
+	}
+	if _, ok := c.selectors[gvk.GroupKind()]; ok {
+		return fmt.Errorf("field indexes are not supported for %s with a label selector", gvk.Kind)
+	}
+	return c.Cache.IndexField(obj, field, extractValue)
+}
+
+// selectorInformer combines the informers of all watched namespaces.
+type selectorInformer struct {
+	gvk       schema.GroupVersionKind
+	informers []toolscache.SharedIndexInformer
+}
+
+var _ cache.Informer = (*selectorInformer)(nil)
+
+func (i *selectorInformer) run(stop <-chan struct{}) {
+	for _, informer := range i.informers {
+		go informer.Run(stop)
+	}
+}
+
+func (i *selectorInformer) AddEventHandler(handler toolscache.ResourceEventHandler) {
+	for _, informer := range i.informers {
+		informer.AddEventHandler(handler)
+	}
+}
+
+func (i *selectorInformer) AddEventHandlerWithResyncPeriod(
+	handler toolscache.ResourceEventHandler, resyncPeriod time.Duration) {
+	for _, informer := range i.informers {
+		informer.AddEventHandlerWithResyncPeriod(handler, resyncPeriod)
+	}
+}
+
+func (i *selectorInformer) AddIndexers(indexers toolscache.Indexers) error {
+	for _, informer := range i.informers {
+		if err := informer.AddIndexers(indexers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *selectorInformer) HasSynced() bool {
+	for _, informer := range i.informers {
+		if !informer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// parseSelector parses a label selector in the kubectl --selector syntax.
+func parseSelector(s string) (labels.Selector, error) {
+	selector, err := labels.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("parsing label selector %q: %w", s, err)
+	}
+	return selector, nil
+}
@@ -0,0 +1,315 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route42plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	route42v1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
+)
+
+// fakeAPIServer serves lists of RecordSets and ClusterZones,
+// filtered by namespace and label selector like the API server,
+// and records the lists it was asked for.
+type fakeAPIServer struct {
+	recordSets   []route42v1alpha1.RecordSet
+	clusterZones []route42v1alpha1.ClusterZone
+	done         chan struct{}
+
+	mu    sync.Mutex
+	lists []string
+}
+
+func (s *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("watch") == "true" {
+		// no changes, until the test is done
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		select {
+		case <-s.done:
+		case <-r.Context().Done():
+		}
+		return
+	}
+
+	selector := r.URL.Query().Get("labelSelector")
+	s.mu.Lock()
+	s.lists = append(s.lists, strings.TrimSpace(r.URL.Path+" "+selector))
+	s.mu.Unlock()
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/apis/"+route42v1alpha1.GroupVersion.String()+"/")
+	var namespace string
+	if strings.HasPrefix(path, "namespaces/") {
+		parts := strings.SplitN(path, "/", 3)
+		namespace, path = parts[1], parts[2]
+	}
+	include := func(obj metav1.Object) bool {
+		return (namespace == "" || obj.GetNamespace() == namespace) &&
+			sel.Matches(labels.Set(obj.GetLabels()))
+	}
+
+	var (
+		list runtime.Object
+		kind string
+	)
+	switch path {
+	case "recordsets":
+		l := &route42v1alpha1.RecordSetList{}
+		for i := range s.recordSets {
+			if include(&s.recordSets[i]) {
+				l.Items = append(l.Items, s.recordSets[i])
+			}
+		}
+		list, kind = l, "RecordSetList"
+	case "clusterzones":
+		l := &route42v1alpha1.ClusterZoneList{}
+		for i := range s.clusterZones {
+			if include(&s.clusterZones[i]) {
+				l.Items = append(l.Items, s.clusterZones[i])
+			}
+		}
+		list, kind = l, "ClusterZoneList"
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	list.GetObjectKind().SetGroupVersionKind(route42v1alpha1.GroupVersion.WithKind(kind))
+	list.(metav1.ListInterface).SetResourceVersion("1")
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+func TestNewCacheFunc(t *testing.T) {
+	public := labels.SelectorFromSet(labels.Set{"agent": "public"})
+	recordSet := func(namespace, name string, public bool) route42v1alpha1.RecordSet {
+		recordSet := route42v1alpha1.RecordSet{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		}
+		if public {
+			recordSet.Labels = map[string]string{"agent": "public"}
+		}
+		return recordSet
+	}
+	clusterZone := func(name string, public bool) route42v1alpha1.ClusterZone {
+		clusterZone := route42v1alpha1.ClusterZone{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+		}
+		if public {
+			clusterZone.Labels = map[string]string{"agent": "public"}
+		}
+		return clusterZone
+	}
+	recordSetKind := schema.GroupKind{Group: route42v1alpha1.GroupVersion.Group, Kind: "RecordSet"}
+	clusterZoneKind := schema.GroupKind{Group: route42v1alpha1.GroupVersion.Group, Kind: "ClusterZone"}
+
+	const api = "/apis/route42.thetechnick.ninja/v1alpha1"
+	tests := []struct {
+		name       string
+		namespaces []string
+		selectors  map[schema.GroupKind]labels.Selector
+		// lists sent to the API server
+		lists []string
+		// objects in the cache
+		recordSets   []string
+		clusterZones []string
+	}{
+		{
+			name: "all namespaces",
+			lists: []string{
+				api + "/clusterzones",
+				api + "/recordsets",
+			},
+			recordSets:   []string{"a/private", "a/public", "b/public", "c/public"},
+			clusterZones: []string{"private.example", "public.example"},
+		},
+		{
+			name:       "namespaces",
+			namespaces: []string{"a", "b"},
+			lists: []string{
+				api + "/clusterzones",
+				api + "/namespaces/a/recordsets",
+				api + "/namespaces/b/recordsets",
+			},
+			recordSets:   []string{"a/private", "a/public", "b/public"},
+			clusterZones: []string{"private.example", "public.example"},
+		},
+		{
+			name:      "selectors in all namespaces",
+			selectors: map[schema.GroupKind]labels.Selector{recordSetKind: public, clusterZoneKind: public},
+			lists: []string{
+				api + "/clusterzones agent=public",
+				api + "/recordsets agent=public",
+			},
+			recordSets:   []string{"a/public", "b/public", "c/public"},
+			clusterZones: []string{"public.example"},
+		},
+		{
+			name:       "selector in one namespace",
+			namespaces: []string{"a"},
+			selectors:  map[schema.GroupKind]labels.Selector{recordSetKind: public},
+			lists: []string{
+				api + "/clusterzones",
+				api + "/namespaces/a/recordsets agent=public",
+			},
+			recordSets:   []string{"a/public"},
+			clusterZones: []string{"private.example", "public.example"},
+		},
+		{
+			name:       "selectors in namespaces",
+			namespaces: []string{"a", "b"},
+			selectors:  map[schema.GroupKind]labels.Selector{recordSetKind: public, clusterZoneKind: public},
+			lists: []string{
+				api + "/clusterzones agent=public",
+				api + "/namespaces/a/recordsets agent=public",
+				api + "/namespaces/b/recordsets agent=public",
+			},
+			recordSets:   []string{"a/public", "b/public"},
+			clusterZones: []string{"public.example"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			apiServer := &fakeAPIServer{
+				recordSets: []route42v1alpha1.RecordSet{
+					recordSet("a", "private", false),
+					recordSet("a", "public", true),
+					recordSet("b", "public", true),
+					recordSet("c", "public", true),
+				},
+				clusterZones: []route42v1alpha1.ClusterZone{
+					clusterZone("private.example", false),
+					clusterZone("public.example", true),
+				},
+				done: make(chan struct{}),
+			}
+			server := httptest.NewServer(apiServer)
+			defer server.Close()
+			defer close(apiServer.done)
+
+			mapper := meta.NewDefaultRESTMapper(nil)
+			mapper.Add(route42v1alpha1.GroupVersion.WithKind("RecordSet"), meta.RESTScopeNamespace)
+			mapper.Add(route42v1alpha1.GroupVersion.WithKind("ClusterZone"), meta.RESTScopeRoot)
+			c, err := newCacheFunc(test.namespaces, test.selectors)(
+				&rest.Config{Host: server.URL}, cache.Options{Scheme: scheme, Mapper: mapper})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// informers are created before the cache is started, like by a manager
+			for _, obj := range []runtime.Object{
+				&route42v1alpha1.RecordSet{}, &route42v1alpha1.ClusterZone{},
+			} {
+				if _, err := c.GetInformer(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+			stop := make(chan struct{})
+			defer close(stop)
+			go func() { _ = c.Start(stop) }()
+			if !c.WaitForCacheSync(stop) {
+				t.Fatal("cache did not sync")
+			}
+
+			ctx := context.Background()
+			recordSetList := &route42v1alpha1.RecordSetList{}
+			if err := c.List(ctx, recordSetList); err != nil {
+				t.Fatal(err)
+			}
+			var recordSets []string
+			for _, recordSet := range recordSetList.Items {
+				recordSets = append(recordSets, recordSet.Namespace+"/"+recordSet.Name)
+			}
+			sort.Strings(recordSets)
+			if !reflect.DeepEqual(recordSets, test.recordSets) {
+				t.Errorf("got RecordSets %v, want %v", recordSets, test.recordSets)
+			}
+
+			clusterZoneList := &route42v1alpha1.ClusterZoneList{}
+			if err := c.List(ctx, clusterZoneList); err != nil {
+				t.Fatal(err)
+			}
+			var clusterZones []string
+			for _, clusterZone := range clusterZoneList.Items {
+				clusterZones = append(clusterZones, clusterZone.Name)
+			}
+			sort.Strings(clusterZones)
+			if !reflect.DeepEqual(clusterZones, test.clusterZones) {
+				t.Errorf("got ClusterZones %v, want %v", clusterZones, test.clusterZones)
+			}
+
+			recordSetList = &route42v1alpha1.RecordSetList{}
+			if err := c.List(ctx, recordSetList, client.InNamespace("b")); err != nil {
+				t.Fatal(err)
+			}
+			for _, recordSet := range recordSetList.Items {
+				if recordSet.Namespace != "b" {
+					t.Errorf("listing namespace b returned RecordSet %s/%s",
+						recordSet.Namespace, recordSet.Name)
+				}
+			}
+
+			err = c.Get(ctx, client.ObjectKey{Namespace: "a", Name: "private"}, &route42v1alpha1.RecordSet{})
+			if want := contains(test.recordSets, "a/private"); (err == nil) != want {
+				t.Errorf("got error %v getting RecordSet a/private, want found %v", err, want)
+			}
+			if err != nil && !apierrors.IsNotFound(err) {
+				t.Errorf("got error %v, want NotFound", err)
+			}
+
+			apiServer.mu.Lock()
+			lists := append([]string(nil), apiServer.lists...)
+			apiServer.mu.Unlock()
+			sort.Strings(lists)
+			if !reflect.DeepEqual(lists, test.lists) {
+				t.Errorf("got lists %q, want %q", lists, test.lists)
+			}
+		})
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ACL lists the client networks allowed to query a zone.
+// A nil ACL allows all clients, an empty ACL refuses all clients.
+type ACL []*net.IPNet
+
+// ParseACL parses the AllowQuery CIDRs of a zone.
+// Invalid CIDRs are reported and skipped, so an ACL with only invalid
+// entries refuses all clients instead of allowing them.
+func ParseACL(cidrs []string) (ACL, error) {
+	if len(cidrs) == 0 {
+		return nil, nil
+	}
+
+	acl := ACL{}
+	var invalid []string
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			invalid = append(invalid, cidr)
+			continue
+		}
+		acl = append(acl, n)
+	}
+	if len(invalid) > 0 {
+		return acl, fmt.Errorf("invalid CIDRs: %s", strings.Join(invalid, ", "))
+	}
+	return acl, nil
+}
+
+// Allowed returns true if the client may query the zone.
+func (a ACL) Allowed(client net.IP) bool {
+	if a == nil {
+		return true
+	}
+	for _, n := range a {
+		if n.Contains(client) {
+			return true
+		}
+	}
+	return false
+}
+
+// Strings returns the networks of the ACL in CIDR notation.
+func (a ACL) Strings() []string {
+	if a == nil {
+		return nil
+	}
+	cidrs := make([]string, len(a))
+	for i, n := range a {
+		cidrs[i] = n.String()
+	}
+	return cidrs
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestParseACL(t *testing.T) {
+	tests := []struct {
+		name    string
+		cidrs   []string
+		want    []string
+		invalid bool
+	}{
+		{name: "no entries allow all clients"},
+		{
+			name:  "networks",
+			cidrs: []string{"10.0.0.0/8", "2001:db8::/32"},
+			want:  []string{"10.0.0.0/8", "2001:db8::/32"},
+		},
+		{
+			name:  "host bits are masked",
+			cidrs: []string{"192.0.2.10/24"},
+			want:  []string{"192.0.2.0/24"},
+		},
+		{
+			name:    "invalid entries are skipped",
+			cidrs:   []string{"10.0.0.0/8", "192.0.2.10"},
+			want:    []string{"10.0.0.0/8"},
+			invalid: true,
+		},
+		{
+			name:    "only invalid entries refuse all clients",
+			cidrs:   []string{"not-a-network"},
+			want:    []string{},
+			invalid: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			acl, err := ParseACL(test.cidrs)
+			if (err != nil) != test.invalid {
+				t.Errorf("got error %v, want invalid %v", err, test.invalid)
+			}
+			if got := acl.Strings(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got ACL %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestACLAllowed(t *testing.T) {
+	acl, err := ParseACL([]string{"10.0.0.0/8", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		acl     ACL
+		client  string
+		allowed bool
+	}{
+		{acl: nil, client: "192.0.2.1", allowed: true},
+		{acl: ACL{}, client: "10.1.2.3"},
+		{acl: acl, client: "10.1.2.3", allowed: true},
+		{acl: acl, client: "2001:db8::1", allowed: true},
+		{acl: acl, client: "192.0.2.1"},
+		{acl: acl, client: "2001:db9::1"},
+		// IPv4-mapped IPv6 addresses match IPv4 networks
+		{acl: acl, client: "::ffff:10.1.2.3", allowed: true},
+	}
+	for _, test := range tests {
+		if got := test.acl.Allowed(net.ParseIP(test.client)); got != test.allowed {
+			t.Errorf("%v from %s: got allowed %v, want %v",
+				test.acl.Strings(), test.client, got, test.allowed)
+		}
+	}
+}
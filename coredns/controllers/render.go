@@ -0,0 +1,288 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	route42v1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
+)
+
+// ZoneSource is the configuration of a Zone or ClusterZone.
+type ZoneSource struct {
+	// Name of the zone, without trailing dot.
+	Name string
+	Zone route42v1alpha1.ZoneConfig
+}
+
+// ZoneSources combines ClusterZones and Zones into a single list.
+// ClusterZones take precedence over namespaced Zones with the same name.
+func ZoneSources(
+	clusterZones []route42v1alpha1.ClusterZone, zones []route42v1alpha1.Zone,
+) []ZoneSource {
+	var sources []ZoneSource
+	seen := map[string]struct{}{}
+	for _, zone := range clusterZones {
+		seen[zone.Name] = struct{}{}
+		sources = append(sources, ZoneSource{Name: zone.Name, Zone: zone.Zone})
+	}
+	for _, zone := range zones {
+		if _, ok := seen[zone.Name]; ok {
+			continue
+		}
+		seen[zone.Name] = struct{}{}
+		sources = append(sources, ZoneSource{Name: zone.Name, Zone: zone.Zone})
+	}
+	return sources
+}
+
+// ZoneRecordSets returns all RecordSets belonging to the zone.
+// RecordSets that lose a conflict are skipped, conflicts are found once
+// for all zones with route42v1alpha1.FindConflicts.
+func ZoneRecordSets(
+	zone string, recordSets []route42v1alpha1.RecordSet,
+	conflicts map[types.NamespacedName]route42v1alpha1.Conflict,
+) []route42v1alpha1.RecordSet {
+	var inZoneRecordSets []route42v1alpha1.RecordSet
+	for _, recordSet := range recordSets {
+		if !recordSet.Record.InZone(zone) {
+			continue
+		}
+		key := types.NamespacedName{Name: recordSet.Name, Namespace: recordSet.Namespace}
+		if _, ok := conflicts[key]; ok {
+			continue
+		}
+		inZoneRecordSets = append(inZoneRecordSets, recordSet)
+	}
+	return inZoneRecordSets
+}
+
+// ZoneRecords returns the records of all RecordSets belonging to the zone,
+// with their target references resolved.
+// RecordSets that lose a conflict are skipped.
+// Target references that can not be resolved are left out of the records
+// and reported in the returned error, the records are returned regardless.
+func ZoneRecords(
+	zone string, recordSets []route42v1alpha1.RecordSet,
+	conflicts map[types.NamespacedName]route42v1alpha1.Conflict, targets *Targets,
+) ([]route42v1alpha1.Record, error) {
+	zoneRecordSets, err := targets.ResolveRecordSets(
+		zone, ZoneRecordSets(zone, recordSets, conflicts))
+	var records []route42v1alpha1.Record
+	for _, recordSet := range zoneRecordSets {
+		records = append(records, recordSet.Record)
+	}
+	return records, err
+}
+
+// RRsetKey identifies an RRset by its lower case owner name and type.
+type RRsetKey struct {
+	Name string
+	Type uint16
+}
+
+// Owners maps RRsets to the RecordSets contributing to them.
+type Owners map[RRsetKey][]types.NamespacedName
+
+// RecordSetOwners indexes the given RecordSets by the RRset they contribute to.
+func RecordSetOwners(recordSets []route42v1alpha1.RecordSet) Owners {
+	owners := Owners{}
+	for _, recordSet := range recordSets {
+		for _, typed := range recordSet.Record.GetRRsets() {
+			if len(typed.Values()) == 0 {
+				continue
+			}
+			key := RRsetKey{
+				Name: strings.ToLower(recordSet.Record.FQDN()),
+				Type: dns.StringToType[string(typed.GetType())],
+			}
+			owners[key] = append(owners[key], types.NamespacedName{
+				Name:      recordSet.Name,
+				Namespace: recordSet.Namespace,
+			})
+		}
+	}
+	return owners
+}
+
+// RenderZone creates the resource records of a zone,
+// starting with its SOA record.
+func RenderZone(zone ZoneSource, records []route42v1alpha1.Record) ([]dns.RR, error) {
+	soa, err := soaRecord(dns.Fqdn(zone.Name), zone.Zone.SOA)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SOA record: %w", err)
+	}
+	rrs := []dns.RR{soa}
+
+	for _, set := range mergeRecords(zone.Name, records) {
+		for _, v := range set.Values {
+			if set.Type == route42v1alpha1.RecordTypeTXT {
+				v = txtData(v)
+			}
+			rfc1035 := fmt.Sprintf(
+				"%s %d IN %s %s", set.Name, set.TTL, string(set.Type), v)
+			rr, err := dns.NewRR(rfc1035)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create DNS record: %w", err)
+			}
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs, nil
+}
+
+// WriteZoneFile writes the resource records as RFC 1035 master file.
+// Records are written in canonical order with the SOA record first,
+// so the same zone content always results in the same file.
+func WriteZoneFile(w io.Writer, origin string, rrs []dns.RR) error {
+	sorted := make([]dns.RR, len(rrs))
+	copy(sorted, rrs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return lessRR(sorted[i], sorted[j])
+	})
+
+	if _, err := fmt.Fprintf(w, "$ORIGIN %s\n", dns.Fqdn(origin)); err != nil {
+		return err
+	}
+	for _, rr := range sorted {
+		if _, err := fmt.Fprintln(w, rr.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lessRR orders SOA records first, then by owner name in canonical order
+// (RFC 4034, section 6.1), type and record data.
+func lessRR(a, b dns.RR) bool {
+	ah, bh := a.Header(), b.Header()
+	if (ah.Rrtype == dns.TypeSOA) != (bh.Rrtype == dns.TypeSOA) {
+		return ah.Rrtype == dns.TypeSOA
+	}
+	if c := compareNames(ah.Name, bh.Name); c != 0 {
+		return c < 0
+	}
+	if ah.Rrtype != bh.Rrtype {
+		return ah.Rrtype < bh.Rrtype
+	}
+	return a.String() < b.String()
+}
+
+// compareNames compares domain names label by label, starting at the root.
+func compareNames(a, b string) int {
+	al := dns.SplitDomainName(strings.ToLower(a))
+	bl := dns.SplitDomainName(strings.ToLower(b))
+	for i, j := len(al)-1, len(bl)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if c := strings.Compare(al[i], bl[j]); c != 0 {
+			return c
+		}
+	}
+	return len(al) - len(bl)
+}
+
+// rrset holds the merged values of all records with the same name and type.
+type rrset struct {
+	Name   string
+	Type   route42v1alpha1.RecordType
+	TTL    int
+	Values []string
+}
+
+// mergeRecords combines records with the same name and type into a single
+// RRset with unique values, using the lowest TTL of all contributors.
+func mergeRecords(origin string, records []route42v1alpha1.Record) []*rrset {
+	var sets []*rrset
+	index := map[string]*rrset{}
+	seen := map[string]struct{}{}
+	for _, record := range records {
+		for _, typed := range record.GetRRsets() {
+			values := typed.Values()
+			if len(values) == 0 {
+				continue
+			}
+
+			name := record.OwnerName(origin)
+			key := strings.ToLower(name) + "/" + string(typed.GetType())
+			set, ok := index[key]
+			if !ok {
+				set = &rrset{
+					Name: name,
+					Type: typed.GetType(),
+					TTL:  ttl(*typed.TTL),
+				}
+				index[key] = set
+				sets = append(sets, set)
+			}
+			if t := ttl(*typed.TTL); t < set.TTL {
+				set.TTL = t
+			}
+
+			for _, v := range values {
+				if _, ok := seen[key+"/"+v]; ok {
+					continue
+				}
+				seen[key+"/"+v] = struct{}{}
+				set.Values = append(set.Values, v)
+			}
+		}
+	}
+	return sets
+}
+
+// txtData quotes the segments of a TXT value,
+// escaping quotes, backslashes and non-printable bytes.
+func txtData(value string) string {
+	segments := route42v1alpha1.TXTSegments(value)
+	quoted := make([]string, len(segments))
+	for i, segment := range segments {
+		var b strings.Builder
+		b.WriteByte('"')
+		for j := 0; j < len(segment); j++ {
+			switch c := segment[j]; {
+			case c == '"' || c == '\\':
+				b.WriteByte('\\')
+				b.WriteByte(c)
+			case c < ' ' || c > '~':
+				fmt.Fprintf(&b, "\\%03d", c)
+			default:
+				b.WriteByte(c)
+			}
+		}
+		b.WriteByte('"')
+		quoted[i] = b.String()
+	}
+	return strings.Join(quoted, " ")
+}
+
+func ttl(d metav1.Duration) int {
+	return int(d.Duration.Seconds())
+}
+
+// Creates a SOA record for the given zone.
+func soaRecord(zoneName string, soa route42v1alpha1.SOARecord) (dns.RR, error) {
+	v := fmt.Sprintf("%s %s %d %d %d %d %d", soa.Master, soa.Admin, soa.Serial,
+		ttl(soa.Refresh), ttl(soa.Retry), ttl(soa.Expire), ttl(soa.NegativeTTL))
+	rfc1035 := fmt.Sprintf("%s %d IN %s %s", zoneName, ttl(soa.TTL), "SOA", v)
+	return dns.NewRR(rfc1035)
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	route42v1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
+)
+
+func TestMergeRecords(t *testing.T) {
+	hour := &metav1.Duration{Duration: time.Hour}
+	records := []route42v1alpha1.Record{
+		{
+			DNSName: "www.example.com.",
+			TTL:     metav1.Duration{Duration: time.Hour},
+			RecordConfig: route42v1alpha1.RecordConfig{
+				A: []string{"192.0.2.1", "192.0.2.2"},
+			},
+		},
+		{
+			DNSName: "WWW.example.com",
+			TTL:     metav1.Duration{Duration: time.Minute},
+			RecordConfig: route42v1alpha1.RecordConfig{
+				A: []string{"192.0.2.2", "192.0.2.3"},
+			},
+		},
+		{
+			DNSName: "www",
+			ZoneRef: "example.com",
+			RRsets: []route42v1alpha1.RRset{
+				{TTL: hour, RecordConfig: route42v1alpha1.RecordConfig{TXT: []string{"hello"}}},
+				{TTL: hour, RecordConfig: route42v1alpha1.RecordConfig{A: []string{"192.0.2.4"}}},
+			},
+		},
+	}
+
+	sets := mergeRecords("example.com", records)
+	want := []*rrset{
+		{
+			Name:   "www.example.com.",
+			Type:   route42v1alpha1.RecordTypeA,
+			TTL:    60,
+			Values: []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4"},
+		},
+		{
+			Name:   "www.example.com.",
+			Type:   route42v1alpha1.RecordTypeTXT,
+			TTL:    3600,
+			Values: []string{"hello"},
+		},
+	}
+	if !reflect.DeepEqual(sets, want) {
+		for _, set := range sets {
+			t.Logf("%+v", *set)
+		}
+		t.Error("unexpected RRsets")
+	}
+}
+
+func TestZoneRecordSets(t *testing.T) {
+	recordSet := func(name, dnsName string) route42v1alpha1.RecordSet {
+		return route42v1alpha1.RecordSet{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Record: route42v1alpha1.Record{
+				DNSName: dnsName,
+				RecordConfig: route42v1alpha1.RecordConfig{
+					A: []string{"192.0.2.1"},
+				},
+			},
+		}
+	}
+	recordSets := []route42v1alpha1.RecordSet{
+		recordSet("www", "www.example.com."),
+		recordSet("conflicted", "www.example.com."),
+		recordSet("other", "www.example.org."),
+	}
+	conflicts := map[types.NamespacedName]route42v1alpha1.Conflict{
+		{Name: "conflicted", Namespace: "default"}: {},
+	}
+
+	var names []string
+	for _, recordSet := range ZoneRecordSets("example.com", recordSets, conflicts) {
+		names = append(names, recordSet.Name)
+	}
+	if want := []string{"www"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got RecordSets %v, want %v", names, want)
+	}
+}
+
+func TestZoneSources(t *testing.T) {
+	config := func(master string) route42v1alpha1.ZoneConfig {
+		return route42v1alpha1.ZoneConfig{SOA: route42v1alpha1.SOARecord{Master: master}}
+	}
+	clusterZone := func(name, master string) route42v1alpha1.ClusterZone {
+		return route42v1alpha1.ClusterZone{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Zone:       config(master),
+		}
+	}
+	zone := func(namespace, name, master string) route42v1alpha1.Zone {
+		return route42v1alpha1.Zone{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Zone:       config(master),
+		}
+	}
+
+	tests := []struct {
+		name         string
+		clusterZones []route42v1alpha1.ClusterZone
+		zones        []route42v1alpha1.Zone
+		want         []ZoneSource
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:         "ClusterZones and Zones",
+			clusterZones: []route42v1alpha1.ClusterZone{clusterZone("example.com", "ns1.cluster.")},
+			zones:        []route42v1alpha1.Zone{zone("default", "example.org", "ns1.default.")},
+			want: []ZoneSource{
+				{Name: "example.com", Zone: config("ns1.cluster.")},
+				{Name: "example.org", Zone: config("ns1.default.")},
+			},
+		},
+		{
+			name:         "ClusterZone shadows Zone",
+			clusterZones: []route42v1alpha1.ClusterZone{clusterZone("example.com", "ns1.cluster.")},
+			zones: []route42v1alpha1.Zone{
+				zone("default", "example.com", "ns1.default."),
+				zone("other", "example.com", "ns1.other."),
+			},
+			want: []ZoneSource{
+				{Name: "example.com", Zone: config("ns1.cluster.")},
+			},
+		},
+		{
+			name: "first Zone wins across namespaces",
+			zones: []route42v1alpha1.Zone{
+				zone("default", "example.com", "ns1.default."),
+				zone("other", "example.com", "ns1.other."),
+			},
+			want: []ZoneSource{
+				{Name: "example.com", Zone: config("ns1.default.")},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ZoneSources(test.clusterZones, test.zones)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
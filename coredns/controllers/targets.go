@@ -0,0 +1,421 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	route42v1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
+)
+
+// Target is the live hostname and addresses of a referenced object.
+type Target struct {
+	// Hostname of the target, empty if it is only reachable by address.
+	Hostname string
+	A        []string
+	AAAA     []string
+}
+
+// AliasTarget holds the addresses an ALIAS target resolved to.
+type AliasTarget struct {
+	A    []string
+	AAAA []string
+	// TTL is the lowest TTL of the records of the target.
+	TTL time.Duration
+}
+
+// ErrAliasPending is returned by AliasResolver.Lookup for targets
+// that have not been resolved yet.
+var ErrAliasPending = errors.New("resolution pending")
+
+// AliasResolver resolves the targets of ALIAS records.
+type AliasResolver interface {
+	// Lookup returns the addresses the fully qualified target resolved to.
+	// If resolving the target again failed, the last addresses are
+	// returned alongside the error.
+	// Lookup must not block, new targets return ErrAliasPending
+	// until they are resolved in the background.
+	Lookup(target string) (AliasTarget, error)
+	// Retain stops resolving all targets, but the given ones.
+	Retain(targets []string)
+}
+
+// Targets resolves TargetRefs of RecordSets to RecordSets and Services.
+type Targets struct {
+	// Aliases resolves ALIAS records, which are left out if nil.
+	Aliases AliasResolver
+
+	recordSets map[types.NamespacedName]route42v1alpha1.RecordSet
+	services   map[types.NamespacedName]corev1.Service
+
+	// ALIAS targets looked up and the errors per RecordSet.
+	aliasTargets map[string]struct{}
+	aliasErrors  route42v1alpha1.AgentAliasErrors
+}
+
+// NewTargets creates Targets for the given objects.
+// RecordSets that lose a conflict can not be referenced.
+func NewTargets(
+	recordSets []route42v1alpha1.RecordSet,
+	conflicts map[types.NamespacedName]route42v1alpha1.Conflict,
+	services []corev1.Service,
+) *Targets {
+	t := &Targets{
+		recordSets: map[types.NamespacedName]route42v1alpha1.RecordSet{},
+		services:   map[types.NamespacedName]corev1.Service{},
+
+		aliasTargets: map[string]struct{}{},
+		aliasErrors:  route42v1alpha1.AgentAliasErrors{},
+	}
+
+	for _, recordSet := range recordSets {
+		key := types.NamespacedName{Name: recordSet.Name, Namespace: recordSet.Namespace}
+		if _, ok := conflicts[key]; ok {
+			continue
+		}
+		t.recordSets[key] = recordSet
+	}
+	for _, service := range services {
+		key := types.NamespacedName{Name: service.Name, Namespace: service.Namespace}
+		t.services[key] = service
+	}
+	return t
+}
+
+// Resolve returns the target referenced from a RecordSet in namespace.
+// Targets in other namespaces must allow references from namespace,
+// so RecordSets can not publish objects their authors may not read.
+func (t *Targets) Resolve(namespace string, ref route42v1alpha1.TargetRef) (Target, error) {
+	key := types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}
+	if key.Namespace == "" {
+		key.Namespace = namespace
+	}
+
+	switch ref.Kind {
+	case route42v1alpha1.TargetKindRecordSet:
+		recordSet, ok := t.recordSets[key]
+		if !ok {
+			return Target{}, fmt.Errorf("RecordSet %s not found", key)
+		}
+		if !route42v1alpha1.ReferenceAllowed(&recordSet, namespace) {
+			return Target{}, referenceNotAllowed(ref.Kind, key, namespace)
+		}
+		return recordSetTarget(recordSet.Record), nil
+
+	case route42v1alpha1.TargetKindService:
+		service, ok := t.services[key]
+		if !ok {
+			return Target{}, fmt.Errorf("Service %s not found", key)
+		}
+		if !route42v1alpha1.ReferenceAllowed(&service, namespace) {
+			return Target{}, referenceNotAllowed(ref.Kind, key, namespace)
+		}
+		target := serviceTarget(service)
+		if target.Hostname == "" && len(target.A) == 0 && len(target.AAAA) == 0 {
+			return Target{}, fmt.Errorf("Service %s has no hostname or address", key)
+		}
+		return target, nil
+	}
+	return Target{}, fmt.Errorf("unsupported target kind %q", ref.Kind)
+}
+
+func referenceNotAllowed(
+	kind route42v1alpha1.TargetKind, key types.NamespacedName, namespace string) error {
+	return fmt.Errorf("%s %s does not allow references from namespace %s, see the %s annotation",
+		kind, key, namespace, route42v1alpha1.AllowReferencesAnnotation)
+}
+
+// ResolveRecordSets returns copies of the RecordSets with all target
+// references replaced by the live values of their targets, as served in
+// the zone with the given origin.
+// References that can not be resolved are left out of the copies,
+// all of them are reported in the returned error.
+func (t *Targets) ResolveRecordSets(
+	origin string, recordSets []route42v1alpha1.RecordSet,
+) ([]route42v1alpha1.RecordSet, error) {
+	var (
+		resolved = make([]route42v1alpha1.RecordSet, len(recordSets))
+		errs     []error
+	)
+	for i := range recordSets {
+		recordSet := recordSets[i].DeepCopy()
+		key := types.NamespacedName{Name: recordSet.Name, Namespace: recordSet.Namespace}
+		record, err := t.resolveRecord(key, origin, recordSet.Record)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("RecordSet %s: %w", key, err))
+		}
+		recordSet.Record = record
+		resolved[i] = *recordSet
+	}
+	return resolved, utilerrors.NewAggregate(errs)
+}
+
+// AliasTargets returns the ALIAS targets looked up so far.
+func (t *Targets) AliasTargets() []string {
+	targets := make([]string, 0, len(t.aliasTargets))
+	for target := range t.aliasTargets {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// AliasErrors returns the errors resolving ALIAS records so far.
+func (t *Targets) AliasErrors() route42v1alpha1.AgentAliasErrors {
+	return t.aliasErrors
+}
+
+// resolveRecord replaces the target references of the RecordSet
+// with the given key.
+// A CNAME at the zone apex, where it would be illegal, or to a target
+// without hostname is flattened into A and AAAA RRsets.
+// Targets at the apex with only a hostname are resolved like ALIAS records.
+func (t *Targets) resolveRecord(
+	key types.NamespacedName, origin string, record route42v1alpha1.Record,
+) (route42v1alpha1.Record, error) {
+	namespace := key.Namespace
+	rrsets := record.GetRRsets()
+	var hasRefs bool
+	for _, rrset := range rrsets {
+		hasRefs = hasRefs || rrset.HasTargetRefs()
+	}
+	if !hasRefs {
+		return record, nil
+	}
+
+	var (
+		resolved []route42v1alpha1.RRset
+		errs     []error
+	)
+	apex := strings.EqualFold(record.OwnerName(origin), dns.Fqdn(origin))
+	for _, rrset := range rrsets {
+		if !rrset.HasTargetRefs() {
+			resolved = append(resolved, rrset)
+			continue
+		}
+
+		if rrset.Alias != nil {
+			rrsets, err := t.resolveAlias(*rrset.Alias, *rrset.TTL)
+			if err != nil {
+				t.aliasErrors[key.String()] = err.Error()
+				errs = append(errs, err)
+			}
+			resolved = append(resolved, rrsets...)
+			continue
+		}
+
+		if ref := rrset.CNameRef; ref != nil {
+			target, err := t.Resolve(namespace, *ref)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if target.Hostname != "" && !apex {
+				resolved = append(resolved, route42v1alpha1.RRset{
+					TTL:          rrset.TTL,
+					RecordConfig: route42v1alpha1.RecordConfig{CName: &target.Hostname},
+				})
+				continue
+			}
+			if len(target.A) == 0 && len(target.AAAA) == 0 {
+				// only a hostname, e.g. of an ExternalName Service or
+				// a load balancer, flatten it like an ALIAS record
+				rrsets, err := t.resolveAlias(target.Hostname, *rrset.TTL)
+				if err != nil {
+					t.aliasErrors[key.String()] = err.Error()
+					errs = append(errs, err)
+				}
+				resolved = append(resolved, rrsets...)
+				continue
+			}
+			if len(target.A) > 0 {
+				resolved = append(resolved, route42v1alpha1.RRset{
+					TTL:          rrset.TTL,
+					RecordConfig: route42v1alpha1.RecordConfig{A: target.A},
+				})
+			}
+			if len(target.AAAA) > 0 {
+				resolved = append(resolved, route42v1alpha1.RRset{
+					TTL:          rrset.TTL,
+					RecordConfig: route42v1alpha1.RecordConfig{AAAA: target.AAAA},
+				})
+			}
+			continue
+		}
+
+		config := rrset.RecordConfig
+		for _, ref := range rrset.NSRefs {
+			host, err := t.resolveHost(namespace, ref)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			config.NS = append(config.NS, host)
+		}
+		config.NSRefs = nil
+
+		config.MX = nil
+		for _, mx := range rrset.MX {
+			if mx.HostRef != nil {
+				host, err := t.resolveHost(namespace, *mx.HostRef)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				mx.Host, mx.HostRef = host, nil
+			}
+			config.MX = append(config.MX, mx)
+		}
+
+		config.SRV = nil
+		for _, srv := range rrset.SRV {
+			if srv.HostRef != nil {
+				host, err := t.resolveHost(namespace, *srv.HostRef)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				srv.Host, srv.HostRef = host, nil
+			}
+			config.SRV = append(config.SRV, srv)
+		}
+		if config.GetType() != route42v1alpha1.RecordTypeUnknown {
+			resolved = append(resolved, route42v1alpha1.RRset{TTL: rrset.TTL, RecordConfig: config})
+		}
+	}
+
+	record.RecordConfig = route42v1alpha1.RecordConfig{}
+	record.RRsets = resolved
+	return record, utilerrors.NewAggregate(errs)
+}
+
+// resolveAlias returns A and AAAA RRsets with the addresses of the target,
+// using the TTL of the target, if it is lower than ttl.
+// The last known addresses are returned alongside an error,
+// if resolving the target again failed.
+// Pending targets are left out without error.
+func (t *Targets) resolveAlias(
+	target string, ttl metav1.Duration,
+) ([]route42v1alpha1.RRset, error) {
+	if t.Aliases == nil {
+		return nil, fmt.Errorf("ALIAS %s: no resolver configured", target)
+	}
+	t.aliasTargets[strings.ToLower(target)] = struct{}{}
+	addrs, err := t.Aliases.Lookup(strings.ToLower(target))
+	if errors.Is(err, ErrAliasPending) {
+		return nil, nil
+	}
+	if err != nil {
+		err = fmt.Errorf("ALIAS %s: %w", target, err)
+	}
+	if addrs.TTL > 0 && addrs.TTL < ttl.Duration {
+		ttl.Duration = addrs.TTL
+	}
+
+	var rrsets []route42v1alpha1.RRset
+	if len(addrs.A) > 0 {
+		rrsets = append(rrsets, route42v1alpha1.RRset{
+			TTL:          &ttl,
+			RecordConfig: route42v1alpha1.RecordConfig{A: addrs.A},
+		})
+	}
+	if len(addrs.AAAA) > 0 {
+		rrsets = append(rrsets, route42v1alpha1.RRset{
+			TTL:          &ttl,
+			RecordConfig: route42v1alpha1.RecordConfig{AAAA: addrs.AAAA},
+		})
+	}
+	return rrsets, err
+}
+
+// resolveHost returns the hostname of the referenced target,
+// which is required for NS, MX and SRV records.
+func (t *Targets) resolveHost(namespace string, ref route42v1alpha1.TargetRef) (string, error) {
+	target, err := t.Resolve(namespace, ref)
+	if err != nil {
+		return "", err
+	}
+	if target.Hostname == "" {
+		return "", fmt.Errorf("%s %s has no hostname", ref.Kind, ref.Name)
+	}
+	return target.Hostname, nil
+}
+
+// recordSetTarget returns the owner name of the record,
+// with the addresses of its own A and AAAA RRsets.
+func recordSetTarget(record route42v1alpha1.Record) Target {
+	target := Target{Hostname: record.FQDN()}
+	for _, rrset := range record.GetRRsets() {
+		target.A = append(target.A, rrset.A...)
+		target.AAAA = append(target.AAAA, rrset.AAAA...)
+	}
+	return target
+}
+
+// serviceTarget returns the external name of the Service,
+// or the hostname and addresses of its load balancer.
+// Without load balancer, the external IPs and then the cluster IP are used.
+func serviceTarget(service corev1.Service) Target {
+	if service.Spec.Type == corev1.ServiceTypeExternalName {
+		return Target{Hostname: dns.Fqdn(service.Spec.ExternalName)}
+	}
+
+	var target Target
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.Hostname != "" && target.Hostname == "" {
+			target.Hostname = dns.Fqdn(ingress.Hostname)
+		}
+		target.addIP(ingress.IP)
+	}
+	if target.Hostname != "" || len(target.A) > 0 || len(target.AAAA) > 0 {
+		return target
+	}
+
+	for _, ip := range service.Spec.ExternalIPs {
+		target.addIP(ip)
+	}
+	if len(target.A) > 0 || len(target.AAAA) > 0 {
+		return target
+	}
+
+	if service.Spec.ClusterIP != corev1.ClusterIPNone {
+		target.addIP(service.Spec.ClusterIP)
+	}
+	return target
+}
+
+func (t *Target) addIP(s string) {
+	ip := net.ParseIP(s)
+	switch {
+	case ip == nil:
+	case ip.To4() != nil:
+		t.A = append(t.A, ip.String())
+	default:
+		t.AAAA = append(t.AAAA, ip.String())
+	}
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	route42v1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
+)
+
+func TestResolveNamespaces(t *testing.T) {
+	service := func(namespace, allow string) corev1.Service {
+		s := corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "lb", Namespace: namespace},
+			Spec:       corev1.ServiceSpec{ClusterIP: "10.0.0.1"},
+		}
+		if allow != "" {
+			s.Annotations = map[string]string{route42v1alpha1.AllowReferencesAnnotation: allow}
+		}
+		return s
+	}
+	targets := NewTargets(nil, nil, []corev1.Service{
+		service("tenant-a", ""),
+		service("shared", "tenant-a, tenant-b"),
+		service("public", "*"),
+		service("private", ""),
+	})
+
+	tests := []struct {
+		ref     route42v1alpha1.TargetRef
+		wantErr bool
+	}{
+		{ref: route42v1alpha1.TargetRef{Kind: route42v1alpha1.TargetKindService, Name: "lb"}},
+		{ref: route42v1alpha1.TargetRef{
+			Kind: route42v1alpha1.TargetKindService, Name: "lb", Namespace: "shared"}},
+		{ref: route42v1alpha1.TargetRef{
+			Kind: route42v1alpha1.TargetKindService, Name: "lb", Namespace: "public"}},
+		{ref: route42v1alpha1.TargetRef{
+			Kind: route42v1alpha1.TargetKindService, Name: "lb", Namespace: "private"}, wantErr: true},
+	}
+	for _, test := range tests {
+		target, err := targets.Resolve("tenant-a", test.ref)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%+v: unexpected error: %v", test.ref, err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(target.A, []string{"10.0.0.1"}) {
+			t.Errorf("%+v: got %+v", test.ref, target)
+		}
+	}
+}
+
+func TestReferencesService(t *testing.T) {
+	recordSet := route42v1alpha1.RecordSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "apex", Namespace: "tenant-a"},
+		Record: route42v1alpha1.Record{
+			DNSName: "example.com.",
+			TTL:     metav1.Duration{Duration: time.Minute},
+			RRsets: []route42v1alpha1.RRset{
+				{RecordConfig: route42v1alpha1.RecordConfig{CNameRef: &route42v1alpha1.TargetRef{
+					Kind: route42v1alpha1.TargetKindService, Name: "ingress", Namespace: "ingress"}}},
+				{RecordConfig: route42v1alpha1.RecordConfig{MX: []route42v1alpha1.MX{{
+					Priority: 10,
+					HostRef:  &route42v1alpha1.TargetRef{Kind: route42v1alpha1.TargetKindService, Name: "mail"}},
+				}}},
+			},
+		},
+	}
+
+	tests := []struct {
+		key  types.NamespacedName
+		want bool
+	}{
+		{types.NamespacedName{Namespace: "ingress", Name: "ingress"}, true},
+		{types.NamespacedName{Namespace: "tenant-a", Name: "mail"}, true},
+		{types.NamespacedName{Namespace: "ingress", Name: "mail"}, false},
+		{types.NamespacedName{Namespace: "tenant-a", Name: "unrelated"}, false},
+	}
+	for _, test := range tests {
+		if got := referencesService(recordSet, test.key); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.key, got, test.want)
+		}
+	}
+}
+
+// staticAliases resolves ALIAS targets from a map, other targets are pending.
+type staticAliases map[string]AliasTarget
+
+func (a staticAliases) Lookup(target string) (AliasTarget, error) {
+	addrs, ok := a[target]
+	if !ok {
+		return AliasTarget{}, ErrAliasPending
+	}
+	return addrs, nil
+}
+
+func (a staticAliases) Retain(targets []string) {}
+
+func TestResolveApexHostname(t *testing.T) {
+	targets := NewTargets(nil, nil, []corev1.Service{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "lb", Namespace: "default"},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+			Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{Hostname: "lb-1234.elb.example.net"}},
+			}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "external", Namespace: "default"},
+			Spec: corev1.ServiceSpec{
+				Type:         corev1.ServiceTypeExternalName,
+				ExternalName: "pending.example.net",
+			},
+		},
+	})
+	targets.Aliases = staticAliases{
+		"lb-1234.elb.example.net.": {A: []string{"192.0.2.1"}, AAAA: []string{"2001:db8::1"}, TTL: 30 * time.Second},
+	}
+
+	record := func(dnsName, service string) route42v1alpha1.Record {
+		return route42v1alpha1.Record{
+			DNSName: dnsName,
+			TTL:     metav1.Duration{Duration: time.Minute},
+			RecordConfig: route42v1alpha1.RecordConfig{CNameRef: &route42v1alpha1.TargetRef{
+				Kind: route42v1alpha1.TargetKindService, Name: service}},
+		}
+	}
+	ttl := &metav1.Duration{Duration: 30 * time.Second}
+	minute := &metav1.Duration{Duration: time.Minute}
+	key := types.NamespacedName{Name: "apex", Namespace: "default"}
+
+	tests := []struct {
+		name   string
+		record route42v1alpha1.Record
+		want   []route42v1alpha1.RRset
+	}{
+		{
+			name:   "apex is flattened with the TTL of the target",
+			record: record("example.com.", "lb"),
+			want: []route42v1alpha1.RRset{
+				{TTL: ttl, RecordConfig: route42v1alpha1.RecordConfig{A: []string{"192.0.2.1"}}},
+				{TTL: ttl, RecordConfig: route42v1alpha1.RecordConfig{AAAA: []string{"2001:db8::1"}}},
+			},
+		},
+		{
+			name:   "pending targets are left out",
+			record: record("example.com.", "external"),
+		},
+		{
+			name:   "CNAME below the apex",
+			record: record("www.example.com.", "lb"),
+			want: []route42v1alpha1.RRset{{TTL: minute, RecordConfig: route42v1alpha1.RecordConfig{
+				CName: strPtr("lb-1234.elb.example.net.")}}},
+		},
+	}
+	for _, test := range tests {
+		record, err := targets.resolveRecord(key, "example.com", test.record)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(record.RRsets, test.want) {
+			t.Errorf("%s: got RRsets %+v, want %+v", test.name, record.RRsets, test.want)
+		}
+	}
+	if want := []string{"lb-1234.elb.example.net.", "pending.example.net."}; !reflect.DeepEqual(targets.AliasTargets(), want) {
+		t.Errorf("got ALIAS targets %v, want %v", targets.AliasTargets(), want)
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -18,17 +18,21 @@ package controllers
 
 import (
 	"context"
-	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/coredns/coredns/plugin/file"
 	"github.com/go-logr/logr"
 	"github.com/miekg/dns"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	route42v1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
@@ -36,20 +40,46 @@ import (
 
 // ZoneReconciler reconciles a Zone object
 type ZoneReconciler struct {
-	client client.Client
+	client client.Reader
 	log    logr.Logger
 
+	// reconcileMu serializes reconciles, which may be triggered from
+	// several goroutines, so zones are built, swapped and handed to
+	// onUpdate in the order the reconciles started.
+	reconcileMu sync.Mutex
+
 	zones     map[string]*file.Zone
 	zoneNames []string
+	owners    map[string]Owners
+	acls      map[string]ACL
 	sync.RWMutex
+
+	aliases  AliasResolver
+	onUpdate []func(Update)
+	// requeue triggers reconciles through the controller.
+	requeue chan event.GenericEvent
+}
+
+// Update holds the content of all zones after a reconcile.
+type Update struct {
+	// Records of all zones, keyed by fully qualified zone name.
+	Records map[string][]dns.RR
+	// ACLs of the zones restricting queries, keyed by fully qualified zone name.
+	ACLs map[string]ACL
+	// AliasErrors of ALIAS records that could not be resolved,
+	// keyed by namespace/name of the RecordSet.
+	AliasErrors route42v1alpha1.AgentAliasErrors
 }
 
-func NewZoneReconciler(c client.Client, log logr.Logger) *ZoneReconciler {
+// NewZoneReconciler creates a ZoneReconciler reading objects from the given
+// client.Reader, which may be backed by a Kubernetes cache or by files.
+func NewZoneReconciler(c client.Reader, log logr.Logger) *ZoneReconciler {
 	return &ZoneReconciler{
 		client: c,
 		log:    log,
 
-		zones: map[string]*file.Zone{},
+		zones:   map[string]*file.Zone{},
+		requeue: make(chan event.GenericEvent, 1),
 	}
 }
 
@@ -62,109 +92,200 @@ func (r *ZoneReconciler) Zone(zone string) (*file.Zone, bool) {
 	return z, ok
 }
 
+// Owners returns the RecordSets contributing to the RRset
+// with the given name and type in zone.
+func (r *ZoneReconciler) Owners(zone, name string, qtype uint16) []types.NamespacedName {
+	return r.owners[zone][RRsetKey{Name: strings.ToLower(name), Type: qtype}]
+}
+
+// ACL returns the clients allowed to query the zone.
+func (r *ZoneReconciler) ACL(zone string) ACL {
+	return r.acls[zone]
+}
+
+// OnUpdate registers a function that is called with the content of all
+// zones after every successful reconcile.
+// Must be called before the reconciler is started.
+func (r *ZoneReconciler) OnUpdate(fn func(Update)) {
+	r.onUpdate = append(r.onUpdate, fn)
+}
+
+// SetAliasResolver sets the resolver for the targets of ALIAS records,
+// which are not served without it.
+// Must be called before the reconciler is started.
+func (r *ZoneReconciler) SetAliasResolver(aliases AliasResolver) {
+	r.aliases = aliases
+}
+
+// Requeue triggers a reconcile through the controller,
+// e.g. after ALIAS targets resolved to new addresses.
+// It never blocks, requests are coalesced until the controller picks them up.
+func (r *ZoneReconciler) Requeue() {
+	select {
+	case r.requeue <- event.GenericEvent{Meta: &metav1.ObjectMeta{Name: "requeue"}}:
+	default:
+	}
+}
+
 // +kubebuilder:rbac:groups=route42.thetechnick.ninja,resources=zones,verbs=get;list;watch
+// +kubebuilder:rbac:groups=route42.thetechnick.ninja,resources=clusterzones,verbs=get;list;watch
 // +kubebuilder:rbac:groups=route42.thetechnick.ninja,resources=recordsets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch
 
 func (r *ZoneReconciler) Reconcile(req ctrl.Request) (result ctrl.Result, err error) {
-	r.Lock()
-	defer r.Unlock()
+	r.reconcileMu.Lock()
+	defer r.reconcileMu.Unlock()
 
 	log := r.log.WithValues("request", req.NamespacedName)
+	defer func(start time.Time) {
+		res := "success"
+		if err != nil {
+			res = "error"
+		}
+		ReconcileDuration.WithLabelValues(res).Observe(time.Since(start).Seconds())
+	}(time.Now())
 
 	ctx := context.Background()
 	zones, err := r.listZones(ctx)
 	if err != nil {
 		return
 	}
+	recordSets, err := r.listRecordSets(ctx)
+	if err != nil {
+		return
+	}
+	serviceList := &corev1.ServiceList{}
+	if err = r.client.List(ctx, serviceList); err != nil {
+		return
+	}
+	conflicts := route42v1alpha1.FindConflicts(recordSets)
+	targets := NewTargets(recordSets, conflicts, serviceList.Items)
+	targets.Aliases = r.aliases
 
 	var zoneNames []string
 	zonesMap := map[string]*file.Zone{}
+	zoneRecords := map[string][]dns.RR{}
+	owners := map[string]Owners{}
+	acls := map[string]ACL{}
 	for _, zone := range zones {
-		zoneName := zone.Name + "."
+		zoneName := dns.Fqdn(zone.Name)
 		z := file.NewZone(zoneName, "")
 		zonesMap[zoneName] = z
 		zoneNames = append(zoneNames, zoneName)
 
-		// SOA record
-		rr, err := soaRecord(zoneName, &zone)
+		acl, err := ParseACL(zone.Zone.AllowQuery)
 		if err != nil {
-			return result, err
+			log.Error(err, "skipping allowQuery entries", "zone", zoneName)
+		}
+		if acl != nil {
+			acls[zoneName] = acl
 		}
-		log.WithValues("rr", rr.String()).V(1).Info("add entry")
-		_ = z.Insert(rr)
 
-		// rest
-		records, err := r.listRecords(ctx, zone.Name)
+		zoneRecordSets, err := targets.ResolveRecordSets(
+			zone.Name, ZoneRecordSets(zone.Name, recordSets, conflicts))
+		if err != nil {
+			log.Error(err, "skipping unresolved targets", "zone", zoneName)
+		}
+		owners[zoneName] = RecordSetOwners(zoneRecordSets)
+		var records []route42v1alpha1.Record
+		for _, recordSet := range zoneRecordSets {
+			records = append(records, recordSet.Record)
+		}
+		rrs, err := RenderZone(zone, records)
 		if err != nil {
 			return result, err
 		}
-
-		for _, record := range records {
-			values := record.Values()
-			if len(values) == 0 {
-				continue
-			}
-
-			for _, v := range values {
-				rfc1035 := fmt.Sprintf(
-					"%s %d IN %s %s", record.DNSName, ttl(record.TTL), string(record.GetType()), v)
-				rr, err := dns.NewRR(rfc1035)
-				if err != nil {
-					return result, fmt.Errorf("failed to create DNS record: %w", err)
-				}
-				log.WithValues("rr", rr.String()).V(1).Info("add entry")
-				_ = z.Insert(rr)
-			}
+		for _, rr := range rrs {
+			log.WithValues("rr", rr.String()).V(1).Info("add entry")
+			_ = z.Insert(rr)
 		}
+		zoneRecords[zoneName] = rrs
+	}
+
+	if r.aliases != nil {
+		r.aliases.Retain(targets.AliasTargets())
 	}
 
+	r.Lock()
 	r.zoneNames = zoneNames
 	r.zones = zonesMap
+	r.owners = owners
+	r.acls = acls
+	r.Unlock()
+
+	update := Update{Records: zoneRecords, ACLs: acls, AliasErrors: targets.AliasErrors()}
+	for _, fn := range r.onUpdate {
+		fn(update)
+	}
 	return
 }
 
 func (r *ZoneReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&route42v1alpha1.Zone{}).
+		Watches(&source.Kind{Type: &route42v1alpha1.ClusterZone{}}, &handler.EnqueueRequestForObject{}).
 		Watches(&source.Kind{Type: &route42v1alpha1.RecordSet{}}, &handler.EnqueueRequestForObject{}).
+		Watches(&source.Kind{Type: &corev1.Service{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.referencedServiceRequests),
+		}).
+		Watches(&source.Channel{Source: r.requeue}, &handler.EnqueueRequestForObject{}).
 		Complete(r)
 }
 
-func (r *ZoneReconciler) listZones(ctx context.Context) ([]route42v1alpha1.Zone, error) {
+// referencedServiceRequests maps Services referenced by a RecordSet
+// to a reconcile, and drops the events of all other Services,
+// so unrelated Service changes do not rebuild all zones.
+func (r *ZoneReconciler) referencedServiceRequests(obj handler.MapObject) []reconcile.Request {
+	recordSets, err := r.listRecordSets(context.Background())
+	if err != nil {
+		r.log.Error(err, "listing RecordSets")
+		return nil
+	}
+	key := types.NamespacedName{Name: obj.Meta.GetName(), Namespace: obj.Meta.GetNamespace()}
+	for _, recordSet := range recordSets {
+		if referencesService(recordSet, key) {
+			return []reconcile.Request{{NamespacedName: key}}
+		}
+	}
+	return nil
+}
+
+// referencesService returns true if any value of the RecordSet
+// references the Service with the given key.
+func referencesService(recordSet route42v1alpha1.RecordSet, key types.NamespacedName) bool {
+	for _, rrset := range recordSet.Record.GetRRsets() {
+		for _, ref := range rrset.TargetRefs() {
+			namespace := ref.Namespace
+			if namespace == "" {
+				namespace = recordSet.Namespace
+			}
+			if ref.Kind == route42v1alpha1.TargetKindService &&
+				ref.Name == key.Name && namespace == key.Namespace {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// listZones returns all ClusterZones and Zones.
+func (r *ZoneReconciler) listZones(ctx context.Context) ([]ZoneSource, error) {
+	clusterZoneList := &route42v1alpha1.ClusterZoneList{}
+	if err := r.client.List(ctx, clusterZoneList); err != nil {
+		return nil, err
+	}
 	zoneList := &route42v1alpha1.ZoneList{}
 	if err := r.client.List(ctx, zoneList); err != nil {
 		return nil, err
 	}
-	return zoneList.Items, nil
+	return ZoneSources(clusterZoneList.Items, zoneList.Items), nil
 }
 
-func (r *ZoneReconciler) listRecords(ctx context.Context, zone string) (
-	[]route42v1alpha1.Record, error) {
+func (r *ZoneReconciler) listRecordSets(ctx context.Context) (
+	[]route42v1alpha1.RecordSet, error) {
 	recordSetList := &route42v1alpha1.RecordSetList{}
 	if err := r.client.List(ctx, recordSetList); err != nil {
 		return nil, err
 	}
-
-	var records []route42v1alpha1.Record
-	for _, recordSet := range recordSetList.Items {
-		if !strings.HasSuffix(recordSet.Record.DNSName, "."+zone) {
-			continue
-		}
-		records = append(records, recordSet.Record)
-	}
-
-	return records, nil
-}
-
-func ttl(d metav1.Duration) int {
-	return int(d.Duration.Seconds())
-}
-
-// Creates a SOA record for the given zone.
-func soaRecord(zoneName string, zone *route42v1alpha1.Zone) (dns.RR, error) {
-	soa := zone.Zone.SOA
-	v := fmt.Sprintf("%s %s %d %d %d %d %d", soa.Master, soa.Admin, soa.Serial,
-		ttl(soa.Refresh), ttl(soa.Retry), ttl(soa.Expire), ttl(soa.NegativeTTL))
-	rfc1035 := fmt.Sprintf("%s %d IN %s %s", zoneName, ttl(soa.TTL), "SOA", v)
-	return dns.NewRR(rfc1035)
+	return recordSetList.Items, nil
 }
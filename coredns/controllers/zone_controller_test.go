@@ -0,0 +1,94 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	route42v1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
+)
+
+func TestReconcileClusterZones(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = route42v1alpha1.AddToScheme(scheme)
+
+	soa := func(master string) route42v1alpha1.ZoneConfig {
+		return route42v1alpha1.ZoneConfig{SOA: route42v1alpha1.SOARecord{
+			Master: master, Admin: "admin.example.com.", Serial: 1,
+		}}
+	}
+	c := fake.NewFakeClientWithScheme(scheme,
+		&route42v1alpha1.ClusterZone{
+			ObjectMeta: metav1.ObjectMeta{Name: "example.com"},
+			Zone:       soa("ns1.cluster.example."),
+		},
+		&route42v1alpha1.Zone{
+			ObjectMeta: metav1.ObjectMeta{Name: "example.com", Namespace: "default"},
+			Zone:       soa("ns1.default.example."),
+		},
+		&route42v1alpha1.Zone{
+			ObjectMeta: metav1.ObjectMeta{Name: "example.org", Namespace: "default"},
+			Zone:       soa("ns1.default.example."),
+		},
+	)
+
+	r := NewZoneReconciler(c, log.NullLogger{})
+	var update Update
+	r.OnUpdate(func(u Update) { update = u })
+	if _, err := r.Reconcile(ctrl.Request{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"example.com.", "example.org."}; !reflect.DeepEqual(r.Zones(), want) {
+		t.Errorf("got zones %v, want %v", r.Zones(), want)
+	}
+	tests := []struct {
+		zone   string
+		master string
+	}{
+		{zone: "example.com.", master: "ns1.cluster.example."},
+		{zone: "example.org.", master: "ns1.default.example."},
+	}
+	for _, test := range tests {
+		if _, ok := r.Zone(test.zone); !ok {
+			t.Errorf("zone %s not served", test.zone)
+		}
+		rrs := update.Records[test.zone]
+		if len(rrs) == 0 {
+			t.Errorf("no records for zone %s", test.zone)
+			continue
+		}
+		soa, ok := rrs[0].(*dns.SOA)
+		if !ok {
+			t.Errorf("first record of zone %s is %s, want SOA", test.zone, rrs[0])
+			continue
+		}
+		if soa.Ns != test.master {
+			t.Errorf("got SOA master %s for zone %s, want %s", soa.Ns, test.zone, test.master)
+		}
+	}
+}
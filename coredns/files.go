@@ -0,0 +1,113 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route42plugin
+
+import (
+	"fmt"
+
+	"gopkg.in/fsnotify.v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/thetechnick/route42/coredns/controllers"
+	"github.com/thetechnick/route42/coredns/manifests"
+)
+
+// runFiles serves Zones and RecordSets from the manifests in p.Directory,
+// instead of watching a Kubernetes API server.
+// Manifests are reloaded whenever the directory changes and zones are
+// rebuilt when ALIAS targets resolve to new addresses,
+// until stop is closed. done is closed after the watch has stopped.
+func (p *route42plugin) runFiles(stop <-chan struct{}, done chan<- struct{}) error {
+	store := manifests.NewStore(p.Directory)
+	zoneReconciler := controllers.NewZoneReconciler(
+		store,
+		ctrl.Log.WithName("controllers").WithName("Zone"),
+	)
+	zoneReconciler.OnUpdate(p.onUpdate)
+	zoneReconciler.SetAliasResolver(p.Aliases)
+	aliasChanged := make(chan struct{}, 1)
+	p.Aliases.OnChange(func() {
+		select {
+		case aliasChanged <- struct{}{}:
+		default:
+		}
+	})
+	p.zones = zoneReconciler
+	if err := reloadFiles(store, zoneReconciler); err != nil {
+		close(done)
+		return err
+	}
+	go func() {
+		_ = p.Aliases.Start(stop)
+	}()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		close(done)
+		return fmt.Errorf("creating watcher: %w", err)
+	}
+	if err := watcher.Add(p.Directory); err != nil {
+		watcher.Close()
+		close(done)
+		return fmt.Errorf("watching %s: %w", p.Directory, err)
+	}
+
+	log := p.log.WithValues("directory", p.Directory)
+	go func() {
+		defer close(done)
+		defer watcher.Close()
+		for {
+			select {
+			case <-stop:
+				return
+
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				log.V(1).Info("directory changed", "event", event.String())
+				if err := reloadFiles(store, zoneReconciler); err != nil {
+					log.Error(err, "reloading manifests, serving previous state")
+				}
+
+			case <-aliasChanged:
+				log.V(1).Info("ALIAS targets changed")
+				if _, err := zoneReconciler.Reconcile(ctrl.Request{}); err != nil {
+					log.Error(err, "building zones, serving previous state")
+				}
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Error(err, "watching directory")
+			}
+		}
+	}()
+	return nil
+}
+
+// reloadFiles loads all manifests and rebuilds the zones.
+func reloadFiles(store *manifests.Store, r *controllers.ZoneReconciler) error {
+	if err := store.Load(); err != nil {
+		return fmt.Errorf("loading manifests: %w", err)
+	}
+	if _, err := r.Reconcile(ctrl.Request{}); err != nil {
+		return fmt.Errorf("building zones: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route42plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/miekg/dns"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	route42v1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
+	"github.com/thetechnick/route42/coredns/controllers"
+)
+
+const (
+	// heartbeatInterval is the interval agents renew their heartbeat Lease in.
+	heartbeatInterval = 30 * time.Second
+	// heartbeatDuration is how long a heartbeat is valid,
+	// so a single missed renewal does not drop the agent.
+	heartbeatDuration = 3 * heartbeatInterval
+)
+
+// heartbeat publishes the zones and serials served by this agent and
+// the ALIAS records it failed to resolve as annotations on a Lease,
+// that is renewed periodically.
+type heartbeat struct {
+	client client.Client
+	reader client.Reader
+	log    logr.Logger
+
+	key      types.NamespacedName
+	identity string
+
+	mu          sync.Mutex
+	zones       route42v1alpha1.AgentZones
+	aliasErrors route42v1alpha1.AgentAliasErrors
+	changed     chan struct{}
+}
+
+// newHeartbeat creates a heartbeat for this agent.
+// The agent is identified by $POD_NAME or the hostname, the Lease is
+// created in $POD_NAMESPACE or the given namespace.
+func newHeartbeat(c client.Client, reader client.Reader, namespace string, log logr.Logger) (*heartbeat, error) {
+	identity := os.Getenv("POD_NAME")
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("getting hostname: %w", err)
+		}
+		identity = hostname
+	}
+	if env := os.Getenv("POD_NAMESPACE"); env != "" {
+		namespace = env
+	}
+
+	return &heartbeat{
+		client:   c,
+		reader:   reader,
+		log:      log,
+		key:      types.NamespacedName{Name: "route42-agent-" + strings.ToLower(identity), Namespace: namespace},
+		identity: identity,
+		changed:  make(chan struct{}, 1),
+	}, nil
+}
+
+// Update records the zones served by the agent and the ALIAS errors,
+// and triggers a renewal.
+func (h *heartbeat) Update(update controllers.Update) {
+	serials := route42v1alpha1.AgentZones{}
+	for zoneName, rrs := range update.Records {
+		for _, rr := range rrs {
+			if soa, ok := rr.(*dns.SOA); ok {
+				serials[zoneName] = int(soa.Serial)
+				break
+			}
+		}
+	}
+
+	h.mu.Lock()
+	h.zones = serials
+	h.aliasErrors = update.AliasErrors
+	h.mu.Unlock()
+
+	select {
+	case h.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Start renews the Lease until stop is closed.
+// Implements manager.Runnable, so it only starts after the caches have synced.
+func (h *heartbeat) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			h.release()
+			return nil
+		case <-ticker.C:
+		case <-h.changed:
+		}
+
+		h.mu.Lock()
+		zones, aliasErrors := h.zones, h.aliasErrors
+		h.mu.Unlock()
+		if zones == nil {
+			// nothing reconciled yet
+			continue
+		}
+		if err := h.renew(context.Background(), zones, aliasErrors); err != nil {
+			h.log.Error(err, "renewing heartbeat", "lease", h.key)
+		}
+	}
+}
+
+// release deletes the Lease, so the agent is removed from the
+// servingAgents of its zones without waiting for the Lease to expire.
+func (h *heartbeat) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      h.key.Name,
+			Namespace: h.key.Namespace,
+		},
+	}
+	if err := h.client.Delete(ctx, lease); err != nil && !apierrors.IsNotFound(err) {
+		h.log.Error(err, "deleting heartbeat", "lease", h.key)
+	}
+}
+
+func (h *heartbeat) renew(
+	ctx context.Context, zones route42v1alpha1.AgentZones,
+	aliasErrors route42v1alpha1.AgentAliasErrors,
+) error {
+	lease := &coordinationv1.Lease{}
+	err := h.reader.Get(ctx, h.key, lease)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	create := apierrors.IsNotFound(err)
+	if create {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      h.key.Name,
+				Namespace: h.key.Namespace,
+			},
+		}
+	}
+
+	if lease.Labels == nil {
+		lease.Labels = map[string]string{}
+	}
+	lease.Labels[route42v1alpha1.AgentLabel] = "true"
+	if err := route42v1alpha1.SetAgentZones(lease, zones); err != nil {
+		return err
+	}
+	if err := route42v1alpha1.SetAgentAliasErrors(lease, aliasErrors); err != nil {
+		return err
+	}
+	now := metav1.NewMicroTime(time.Now())
+	duration := int32(heartbeatDuration / time.Second)
+	lease.Spec.HolderIdentity = &h.identity
+	lease.Spec.LeaseDurationSeconds = &duration
+	lease.Spec.RenewTime = &now
+
+	if create {
+		return h.client.Create(ctx, lease)
+	}
+	return h.client.Update(ctx, lease)
+}
@@ -0,0 +1,329 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package manifests loads Route42 objects and Services from YAML or JSON files.
+package manifests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	route42v1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
+	route42v1alpha2 "github.com/thetechnick/route42/api/v1alpha2"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	_ = route42v1alpha1.AddToScheme(scheme)
+	_ = route42v1alpha2.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
+}
+
+// Objects holds Route42 objects and the Services they may reference.
+type Objects struct {
+	ClusterZones []route42v1alpha1.ClusterZone
+	Zones        []route42v1alpha1.Zone
+	RecordSets   []route42v1alpha1.RecordSet
+	Services     []corev1.Service
+}
+
+// Load reads Route42 objects and Services from the given files.
+// Directories are read non-recursively, only considering .yaml, .yml and
+// .json files. Objects of other kinds are ignored.
+// Defaults are applied to all objects, as the webhook would do when creating
+// them, and namespaced objects without namespace are put into "default".
+func Load(paths ...string) (*Objects, error) {
+	objs := &Objects{}
+	for _, path := range paths {
+		files, err := expandPath(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if err := objs.loadFile(f); err != nil {
+				return nil, fmt.Errorf("loading %s: %w", f, err)
+			}
+		}
+	}
+	return objs, nil
+}
+
+func expandPath(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch filepath.Ext(entry.Name()) {
+		case ".yaml", ".yml", ".json":
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+func (o *Objects) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return o.Decode(f)
+}
+
+// Decode reads all Route42 objects and Services from a multi document
+// YAML or JSON stream.
+func (o *Objects) Decode(r io.Reader) error {
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	reader := yaml.NewYAMLReader(bufio.NewReader(r))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		obj, _, err := decoder.Decode(doc, nil, nil)
+		if runtime.IsNotRegisteredError(err) || runtime.IsMissingKind(err) {
+			// neither a Route42 object nor a Service
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if obj, err = toHub(obj); err != nil {
+			return err
+		}
+
+		switch obj := obj.(type) {
+		case *route42v1alpha1.ClusterZone:
+			obj.Default()
+			o.ClusterZones = append(o.ClusterZones, *obj)
+		case *route42v1alpha1.Zone:
+			obj.Default()
+			defaultNamespace(obj)
+			o.Zones = append(o.Zones, *obj)
+		case *route42v1alpha1.RecordSet:
+			obj.Default()
+			defaultNamespace(obj)
+			o.RecordSets = append(o.RecordSets, *obj)
+		case *corev1.Service:
+			defaultNamespace(obj)
+			o.Services = append(o.Services, *obj)
+		}
+	}
+}
+
+// toHub converts objects of newer API versions to v1alpha1.
+func toHub(obj runtime.Object) (runtime.Object, error) {
+	var hub conversion.Hub
+	switch obj.(type) {
+	case *route42v1alpha2.ClusterZone:
+		hub = &route42v1alpha1.ClusterZone{}
+	case *route42v1alpha2.Zone:
+		hub = &route42v1alpha1.Zone{}
+	case *route42v1alpha2.RecordSet:
+		hub = &route42v1alpha1.RecordSet{}
+	default:
+		return obj, nil
+	}
+	if err := obj.(conversion.Convertible).ConvertTo(hub); err != nil {
+		return nil, err
+	}
+	return hub, nil
+}
+
+func defaultNamespace(obj metav1.Object) {
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(metav1.NamespaceDefault)
+	}
+}
+
+// Store serves objects loaded from manifest files as client.Reader.
+type Store struct {
+	paths []string
+
+	mux  sync.RWMutex
+	objs *Objects
+}
+
+var _ client.Reader = (*Store)(nil)
+
+// NewStore creates a Store for the given files and directories.
+// Load has to be called to read the files.
+func NewStore(paths ...string) *Store {
+	return &Store{
+		paths: paths,
+		objs:  &Objects{},
+	}
+}
+
+// Load (re-)reads all files of the store.
+// The previously loaded objects are kept, if loading fails.
+func (s *Store) Load() error {
+	objs, err := Load(s.paths...)
+	if err != nil {
+		return err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.objs = objs
+	return nil
+}
+
+// Objects returns all currently loaded objects.
+func (s *Store) Objects() *Objects {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.objs
+}
+
+func (s *Store) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	objs := s.Objects()
+
+	switch obj := obj.(type) {
+	case *route42v1alpha1.ClusterZone:
+		for i := range objs.ClusterZones {
+			if objs.ClusterZones[i].Name == key.Name {
+				objs.ClusterZones[i].DeepCopyInto(obj)
+				return nil
+			}
+		}
+	case *route42v1alpha1.Zone:
+		for i := range objs.Zones {
+			if objs.Zones[i].Name == key.Name && objs.Zones[i].Namespace == key.Namespace {
+				objs.Zones[i].DeepCopyInto(obj)
+				return nil
+			}
+		}
+	case *route42v1alpha1.RecordSet:
+		for i := range objs.RecordSets {
+			if objs.RecordSets[i].Name == key.Name && objs.RecordSets[i].Namespace == key.Namespace {
+				objs.RecordSets[i].DeepCopyInto(obj)
+				return nil
+			}
+		}
+	case *corev1.Service:
+		for i := range objs.Services {
+			if objs.Services[i].Name == key.Name && objs.Services[i].Namespace == key.Namespace {
+				objs.Services[i].DeepCopyInto(obj)
+				return nil
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %T", obj)
+	}
+
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return err
+	}
+	return apierrors.NewNotFound(schema.GroupResource{
+		Group:    gvk.Group,
+		Resource: strings.ToLower(gvk.Kind) + "s",
+	}, key.Name)
+}
+
+func (s *Store) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	objs := s.Objects()
+
+	switch list := list.(type) {
+	case *route42v1alpha1.ClusterZoneList:
+		list.Items = nil
+		for _, obj := range objs.ClusterZones {
+			if matches(listOpts, &obj) {
+				list.Items = append(list.Items, *obj.DeepCopy())
+			}
+		}
+	case *route42v1alpha1.ZoneList:
+		list.Items = nil
+		for _, obj := range objs.Zones {
+			if matches(listOpts, &obj) {
+				list.Items = append(list.Items, *obj.DeepCopy())
+			}
+		}
+	case *route42v1alpha1.RecordSetList:
+		list.Items = nil
+		for _, obj := range objs.RecordSets {
+			if matches(listOpts, &obj) {
+				list.Items = append(list.Items, *obj.DeepCopy())
+			}
+		}
+	case *corev1.ServiceList:
+		list.Items = nil
+		for _, obj := range objs.Services {
+			if matches(listOpts, &obj) {
+				list.Items = append(list.Items, *obj.DeepCopy())
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %T", list)
+	}
+	return nil
+}
+
+func matches(opts *client.ListOptions, obj metav1.Object) bool {
+	if opts.Namespace != "" && obj.GetNamespace() != "" &&
+		opts.Namespace != obj.GetNamespace() {
+		return false
+	}
+	if opts.LabelSelector != nil &&
+		!opts.LabelSelector.Matches(labels.Set(obj.GetLabels())) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifests
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	route42v1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
+)
+
+const zoneManifest = `apiVersion: route42.thetechnick.ninja/v1alpha1
+kind: Zone
+metadata:
+  name: example.com
+zone:
+  soa:
+    ttl: 1m
+    master: ns1.example.com.
+    admin: hostmaster.example.com.
+    serial: 1
+`
+
+const recordSetManifests = `---
+apiVersion: route42.thetechnick.ninja/v1alpha2
+kind: RecordSet
+metadata:
+  name: www
+  namespace: web
+  labels:
+    app: web
+spec:
+  dnsName: www.example.com.
+  ttl: 5m
+  records:
+    a:
+    - 192.0.2.1
+---
+# comments only
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ignored
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: lb
+spec:
+  clusterIP: 10.0.0.1
+`
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "manifests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDecode(t *testing.T) {
+	objs := &Objects{}
+	if err := objs.Decode(strings.NewReader(zoneManifest + recordSetManifests)); err != nil {
+		t.Fatal(err)
+	}
+	if len(objs.Zones) != 1 || len(objs.RecordSets) != 1 || len(objs.Services) != 1 {
+		t.Fatalf("got %d Zones, %d RecordSets and %d Services, want one each",
+			len(objs.Zones), len(objs.RecordSets), len(objs.Services))
+	}
+
+	zone := objs.Zones[0]
+	if zone.Namespace != "default" {
+		t.Errorf("got Zone namespace %q, want default", zone.Namespace)
+	}
+	if zone.Zone.DeletionPolicy != route42v1alpha1.DeletionPolicyBlock {
+		t.Errorf("expected defaults to be applied, got deletionPolicy %q",
+			zone.Zone.DeletionPolicy)
+	}
+
+	// v1alpha2 objects are converted to v1alpha1
+	recordSet := objs.RecordSets[0]
+	if recordSet.Namespace != "web" {
+		t.Errorf("got RecordSet namespace %q, want web", recordSet.Namespace)
+	}
+	if a := recordSet.Record.A; len(a) != 1 || a[0] != "192.0.2.1" {
+		t.Errorf("got A records %v", a)
+	}
+	if objs.Services[0].Namespace != "default" {
+		t.Errorf("got Service namespace %q, want default", objs.Services[0].Namespace)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"zone.yaml":          zoneManifest,
+		"records.yml":        recordSetManifests,
+		"README.md":          "not a manifest",
+		"nested/zone.yaml":   zoneManifest,
+		"other/invalid.json": "{",
+	})
+	defer os.RemoveAll(dir)
+
+	objs, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs.Zones) != 1 || len(objs.RecordSets) != 1 {
+		t.Errorf("got %d Zones and %d RecordSets, want one each",
+			len(objs.Zones), len(objs.RecordSets))
+	}
+
+	if _, err := Load(filepath.Join(dir, "other")); err == nil {
+		t.Error("expected an error for an invalid file")
+	}
+	if _, err := Load(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"zone.yaml":    zoneManifest,
+		"records.yaml": recordSetManifests,
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+
+	s := NewStore(dir)
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	zone := &route42v1alpha1.Zone{}
+	if err := s.Get(ctx, client.ObjectKey{Name: "example.com", Namespace: "default"}, zone); err != nil {
+		t.Errorf("getting Zone: %v", err)
+	}
+	err := s.Get(ctx, client.ObjectKey{Name: "example.com", Namespace: "web"}, zone)
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expected a NotFound error, got %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts []client.ListOption
+		want int
+	}{
+		{name: "all namespaces", want: 1},
+		{name: "namespace", opts: []client.ListOption{client.InNamespace("web")}, want: 1},
+		{name: "other namespace", opts: []client.ListOption{client.InNamespace("default")}},
+		{
+			name: "label selector",
+			opts: []client.ListOption{
+				client.MatchingLabelsSelector{Selector: labels.SelectorFromSet(labels.Set{"app": "web"})},
+			},
+			want: 1,
+		},
+		{
+			name: "other labels",
+			opts: []client.ListOption{
+				client.MatchingLabelsSelector{Selector: labels.SelectorFromSet(labels.Set{"app": "mail"})},
+			},
+		},
+	}
+	for _, test := range tests {
+		list := &route42v1alpha1.RecordSetList{}
+		if err := s.List(ctx, list, test.opts...); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if len(list.Items) != test.want {
+			t.Errorf("%s: got %d RecordSets, want %d", test.name, len(list.Items), test.want)
+		}
+	}
+
+	// the previous objects are kept, if loading fails
+	if err := ioutil.WriteFile(filepath.Join(dir, "zone.yaml"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Load(); err == nil {
+		t.Error("expected an error for an invalid file")
+	}
+	services := &corev1.ServiceList{}
+	if err := s.List(ctx, services); err != nil || len(services.Items) != 1 {
+		t.Errorf("expected the previously loaded Service, got %d: %v", len(services.Items), err)
+	}
+}
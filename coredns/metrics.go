@@ -0,0 +1,145 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route42plugin
+
+import (
+	"context"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/thetechnick/route42/coredns/controllers"
+)
+
+var (
+	// snapshotStale is 1 while zones are served from the on-disk snapshot.
+	snapshotStale = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "snapshot_stale",
+		Help:      "Gauge that is 1 while zones are served from a stale snapshot, instead of the synced cache.",
+	})
+
+	queryCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "queries_total",
+		Help:      "Counter of queries answered by route42 per zone, type and rcode.",
+	}, []string{"server", "zone", "type", "rcode"})
+
+	lookupDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "lookup_duration_seconds",
+		Buckets:   plugin.TimeBuckets,
+		Help:      "Histogram of the time (in seconds) each query took to answer.",
+	}, []string{"server", "zone"})
+
+	rateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "rrl_limited_total",
+		Help:      "Counter of responses limited by RRL per response class and action (drop or slip).",
+	}, []string{"server", "class", "action"})
+
+	zoneCount = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "zones",
+		Help:      "Gauge of the number of zones served.",
+	})
+
+	recordCount = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "records",
+		Help:      "Gauge of the number of records per zone, including the SOA record.",
+	}, []string{"zone"})
+
+	zoneSerial = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "serial",
+		Help:      "Gauge of the current SOA serial per zone.",
+	}, []string{"zone"})
+
+	lastReconcile = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "last_reconcile_timestamp_seconds",
+		Help:      "Unix timestamp of the last successful reconcile, subtract it from time() to get its age.",
+	})
+)
+
+// collectors returns all metrics of the plugin for registration.
+func collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		snapshotStale, queryCount, lookupDuration, rateLimited,
+		zoneCount, recordCount, zoneSerial, lastReconcile,
+		controllers.ReconcileDuration,
+	}
+}
+
+// observeQuery records a query answered by the plugin.
+func observeQuery(ctx context.Context, state request.Request, zone string, rcode int, start time.Time) {
+	server := metrics.WithServer(ctx)
+	queryCount.WithLabelValues(server, zone, state.Type(), dns.RcodeToString[rcode]).Inc()
+	lookupDuration.WithLabelValues(server, zone).Observe(time.Since(start).Seconds())
+}
+
+// observeRateLimit records responses that were dropped or slipped by RRL.
+func observeRateLimit(ctx context.Context, class responseClass, action rrlAction) {
+	if action == rrlSend {
+		return
+	}
+	actionName := "drop"
+	if action == rrlSlip {
+		actionName = "slip"
+	}
+	rateLimited.WithLabelValues(
+		metrics.WithServer(ctx), responseClassNames[class], actionName).Inc()
+}
+
+// observeZones updates the zone metrics after a reconcile
+// and removes the metrics of zones that are no longer served.
+func (p *route42plugin) observeZones(zones map[string][]dns.RR) {
+	lastReconcile.SetToCurrentTime()
+	zoneCount.Set(float64(len(zones)))
+
+	zoneNames := map[string]struct{}{}
+	for zoneName, rrs := range zones {
+		zoneNames[zoneName] = struct{}{}
+		recordCount.WithLabelValues(zoneName).Set(float64(len(rrs)))
+		for _, rr := range rrs {
+			if soa, ok := rr.(*dns.SOA); ok {
+				zoneSerial.WithLabelValues(zoneName).Set(float64(soa.Serial))
+				break
+			}
+		}
+	}
+	for zoneName := range p.zoneNames {
+		if _, ok := zoneNames[zoneName]; !ok {
+			recordCount.DeleteLabelValues(zoneName)
+			zoneSerial.DeleteLabelValues(zoneName)
+		}
+	}
+	p.zoneNames = zoneNames
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route42plugin
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	coretest "github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestObserveQuery(t *testing.T) {
+	p, stop := newTestPlugin(t, testManifests)
+	defer stop()
+	ctx := context.WithValue(context.Background(),
+		dnsserver.Key{}, &dnsserver.Server{Addr: "dns://:53"})
+
+	tests := []struct {
+		qname string
+		qtype uint16
+		// labels of the counted query
+		zone, typ, rcode string
+	}{
+		{qname: "www.example.com.", qtype: dns.TypeA, zone: "example.com.", typ: "A", rcode: "NOERROR"},
+		{qname: "www.example.com.", qtype: dns.TypeAAAA, zone: "example.com.", typ: "AAAA", rcode: "NOERROR"},
+		{qname: "missing.example.com.", qtype: dns.TypeA, zone: "example.com.", typ: "A", rcode: "NXDOMAIN"},
+		{qname: "EXAMPLE.COM.", qtype: dns.TypeSOA, zone: "example.com.", typ: "SOA", rcode: "NOERROR"},
+	}
+	for _, test := range tests {
+		t.Run(test.qname+" "+test.typ, func(t *testing.T) {
+			counter := queryCount.WithLabelValues("dns://:53", test.zone, test.typ, test.rcode)
+			before := testutil.ToFloat64(counter)
+
+			m := &dns.Msg{}
+			m.SetQuestion(test.qname, test.qtype)
+			rec := dnstest.NewRecorder(&coretest.ResponseWriter{})
+			if _, err := p.ServeDNS(ctx, rec, m); err != nil {
+				t.Fatal(err)
+			}
+			if got := testutil.ToFloat64(counter) - before; got != 1 {
+				t.Errorf("got %v queries counted, want 1", got)
+			}
+		})
+	}
+}
+
+func TestObserveRateLimit(t *testing.T) {
+	ctx := context.WithValue(context.Background(),
+		dnsserver.Key{}, &dnsserver.Server{Addr: "dns://:53"})
+
+	tests := []struct {
+		class  responseClass
+		action rrlAction
+		// labels of the counted response, empty for sent responses
+		labels string
+	}{
+		{class: classNXDomain, action: rrlSend},
+		{class: classNXDomain, action: rrlDrop, labels: "action=drop,class=nxdomains,server=dns://:53"},
+		{class: classResponse, action: rrlSlip, labels: "action=slip,class=responses,server=dns://:53"},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s %d", responseClassNames[test.class], test.action), func(t *testing.T) {
+			before := metricValues(rateLimited)
+			observeRateLimit(ctx, test.class, test.action)
+			after := metricValues(rateLimited)
+			for labels, value := range after {
+				want := before[labels]
+				if labels == test.labels {
+					want++
+				}
+				if value != want {
+					t.Errorf("got %v responses counted for %s, want %v", value, labels, want)
+				}
+			}
+			if _, ok := after[test.labels]; test.labels != "" && !ok {
+				t.Errorf("no responses counted for %s", test.labels)
+			}
+		})
+	}
+}
+
+func TestObserveZones(t *testing.T) {
+	soa := func(zone string, serial int) dns.RR {
+		return coretest.SOA(fmt.Sprintf(
+			"%s 60 IN SOA ns.%[1]s admin.%[1]s %d 7200 3600 1209600 60", zone, serial))
+	}
+	a := coretest.A("www.example.com. 60 IN A 192.0.2.1")
+
+	tests := []struct {
+		name    string
+		zones   map[string][]dns.RR
+		records map[string]float64
+		serials map[string]float64
+	}{
+		{
+			name: "zones",
+			zones: map[string][]dns.RR{
+				"example.com.": {soa("example.com.", 1), a},
+				"example.org.": {soa("example.org.", 2)},
+			},
+			records: map[string]float64{"zone=example.com.": 2, "zone=example.org.": 1},
+			serials: map[string]float64{"zone=example.com.": 1, "zone=example.org.": 2},
+		},
+		{
+			name: "serial changed",
+			zones: map[string][]dns.RR{
+				"example.com.": {soa("example.com.", 3), a},
+				"example.org.": {soa("example.org.", 2)},
+			},
+			records: map[string]float64{"zone=example.com.": 2, "zone=example.org.": 1},
+			serials: map[string]float64{"zone=example.com.": 3, "zone=example.org.": 2},
+		},
+		{
+			name: "zone removed",
+			zones: map[string][]dns.RR{
+				"example.com.": {soa("example.com.", 3), a},
+			},
+			records: map[string]float64{"zone=example.com.": 2},
+			serials: map[string]float64{"zone=example.com.": 3},
+		},
+	}
+
+	recordCount.Reset()
+	zoneSerial.Reset()
+	p := &route42plugin{}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p.observeZones(test.zones)
+			if got := testutil.ToFloat64(zoneCount); got != float64(len(test.zones)) {
+				t.Errorf("got %v zones, want %v", got, len(test.zones))
+			}
+			if got := metricValues(recordCount); !reflect.DeepEqual(got, test.records) {
+				t.Errorf("got records %v, want %v", got, test.records)
+			}
+			if got := metricValues(zoneSerial); !reflect.DeepEqual(got, test.serials) {
+				t.Errorf("got serials %v, want %v", got, test.serials)
+			}
+		})
+	}
+}
+
+// metricValues returns the values of all metrics of the collector,
+// keyed by their comma separated, sorted name=value label pairs.
+func metricValues(c prometheus.Collector) map[string]float64 {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	values := map[string]float64{}
+	for m := range ch {
+		pb := &dto.Metric{}
+		_ = m.Write(pb)
+		var labels []string
+		for _, label := range pb.Label {
+			labels = append(labels, label.GetName()+"="+label.GetValue())
+		}
+		var value float64
+		switch {
+		case pb.Gauge != nil:
+			value = pb.Gauge.GetValue()
+		case pb.Counter != nil:
+			value = pb.Counter.GetValue()
+		}
+		values[strings.Join(labels, ",")] = value
+	}
+	return values
+}
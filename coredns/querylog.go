@@ -0,0 +1,134 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route42plugin
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// queryLog writes sampled queries answered by the plugin as JSON lines.
+type queryLog struct {
+	// sampling maps zones to the fraction of queries to log,
+	// the root zone "." holds the default.
+	sampling map[string]float64
+	zones    []string
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+type queryLogEntry struct {
+	Time       time.Time           `json:"time"`
+	Client     string              `json:"client"`
+	QName      string              `json:"qname"`
+	QType      string              `json:"qtype"`
+	RCode      string              `json:"rcode"`
+	Zone       string              `json:"zone"`
+	RecordSets []queryLogRecordSet `json:"recordSets,omitempty"`
+	// Latency in seconds.
+	Latency float64 `json:"latency"`
+}
+
+type queryLogRecordSet struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// newQueryLog creates a query log writing to stdout, or to a file that is
+// rotated after maxSize bytes, keeping maxBackups rotated files.
+func newQueryLog(path string, maxSize int64, maxBackups int) (*queryLog, error) {
+	l := &queryLog{
+		sampling: map[string]float64{".": 1},
+		zones:    []string{"."},
+	}
+	if path == "stdout" {
+		l.w = os.Stdout
+		return l, nil
+	}
+	f, err := newRotatingFile(path, maxSize, maxBackups)
+	if err != nil {
+		return nil, err
+	}
+	l.w = f
+	return l, nil
+}
+
+// SetSampling sets the fraction of queries to log for a zone and its subzones.
+func (l *queryLog) SetSampling(zone string, rate float64) {
+	zone = plugin.Host(zone).Normalize()
+	if _, ok := l.sampling[zone]; !ok {
+		l.zones = append(l.zones, zone)
+	}
+	l.sampling[zone] = rate
+}
+
+// Sample decides if a query to the given zone should be logged.
+func (l *queryLog) Sample(zone string) bool {
+	rate := l.sampling[plugin.Zones(l.zones).Matches(zone)]
+	return rate >= 1 || (rate > 0 && rand.Float64() < rate)
+}
+
+// Log writes an entry for a query. owners are the RecordSets that
+// contributed the answer, if known.
+func (l *queryLog) Log(
+	state request.Request, zone string, rcode int,
+	owners []types.NamespacedName, start time.Time,
+) {
+	entry := queryLogEntry{
+		Time:    start.UTC(),
+		Client:  state.IP(),
+		QName:   strings.ToLower(state.Name()),
+		QType:   state.Type(),
+		RCode:   dns.RcodeToString[rcode],
+		Zone:    zone,
+		Latency: time.Since(start).Seconds(),
+	}
+	for _, owner := range owners {
+		entry.RecordSets = append(entry.RecordSets, queryLogRecordSet{
+			Name:      owner.Name,
+			Namespace: owner.Namespace,
+		})
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	b = append(b, '\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.w.Write(b)
+}
+
+// Close closes the log file, if any.
+func (l *queryLog) Close() error {
+	if c, ok := l.w.(io.Closer); ok && l.w != os.Stdout {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route42plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+)
+
+func TestQueryLog(t *testing.T) {
+	p, stop := newTestPlugin(t, testManifests)
+	defer stop()
+
+	var buf bytes.Buffer
+	p.QueryLog = &queryLog{
+		sampling: map[string]float64{".": 1},
+		zones:    []string{"."},
+		w:        &buf,
+	}
+	defer func() { p.QueryLog = nil }()
+
+	for _, qname := range []string{"WWW.example.com.", "missing.example.com."} {
+		m := &dns.Msg{}
+		m.SetQuestion(qname, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{RemoteIP: "198.51.100.10"})
+		if _, err := p.ServeDNS(context.Background(), rec, m); err != nil {
+			t.Fatalf("ServeDNS: %v", err)
+		}
+	}
+
+	var entries []queryLogEntry
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var entry queryLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid log line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d log entries, want 2", len(entries))
+	}
+
+	want := []queryLogEntry{
+		{
+			Client: "198.51.100.10", QName: "www.example.com.", QType: "A",
+			RCode: "NOERROR", Zone: "example.com.",
+			RecordSets: []queryLogRecordSet{{Name: "www", Namespace: "default"}},
+		},
+		{
+			Client: "198.51.100.10", QName: "missing.example.com.", QType: "A",
+			RCode: "NXDOMAIN", Zone: "example.com.",
+		},
+	}
+	for i := range entries {
+		if entries[i].Time.IsZero() || entries[i].Latency < 0 {
+			t.Errorf("entry %d: invalid time %s or latency %f",
+				i, entries[i].Time, entries[i].Latency)
+		}
+		entries[i].Time, entries[i].Latency = want[i].Time, 0
+		if !reflect.DeepEqual(entries[i], want[i]) {
+			t.Errorf("entry %d: got %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+}
+
+func TestQueryLogSampling(t *testing.T) {
+	l := &queryLog{
+		sampling: map[string]float64{".": 1},
+		zones:    []string{"."},
+	}
+	l.SetSampling("example.com", 0)
+	l.SetSampling("keep.example.com.", 1)
+
+	tests := []struct {
+		zone string
+		want bool
+	}{
+		{zone: "example.org.", want: true},
+		{zone: "example.com.", want: false},
+		{zone: "sub.example.com.", want: false},
+		{zone: "keep.example.com.", want: true},
+	}
+	for _, test := range tests {
+		if got := l.Sample(test.zone); got != test.want {
+			t.Errorf("%s: got sampled %v, want %v", test.zone, got, test.want)
+		}
+	}
+}
+
+func TestParseQueryLog(t *testing.T) {
+	tests := []struct {
+		args  []string
+		valid bool
+	}{
+		{args: []string{"stdout"}, valid: true},
+		{args: []string{"stdout", "10"}},
+		{args: []string{"/dev/null", "0"}},
+		{args: []string{"/dev/null", "10", "-1"}},
+		{args: []string{"/dev/null", "10", "0"}, valid: true},
+	}
+	for _, test := range tests {
+		ql, err := parseQueryLog(test.args)
+		if (err == nil) != test.valid {
+			t.Errorf("%v: valid %v, got %v", test.args, test.valid, err)
+		}
+		if ql != nil {
+			_ = ql.Close()
+		}
+	}
+}
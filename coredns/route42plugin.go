@@ -19,21 +19,35 @@ package route42plugin
 import (
 	"context"
 	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/file"
 	"github.com/coredns/coredns/request"
 	"github.com/go-logr/logr"
 	"github.com/miekg/dns"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	route42v1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
 	"github.com/thetechnick/route42/coredns/controllers"
 )
 
-const pluginName = "route42"
+const (
+	pluginName = "route42"
+
+	// syncRetryInterval is the interval to retry the initial reconcile in.
+	syncRetryInterval = 5 * time.Second
+)
 
 var scheme = runtime.NewScheme()
 
@@ -48,22 +62,65 @@ type zones interface {
 
 	Zones() []string
 	Zone(string) (*file.Zone, bool)
+	ACL(string) controllers.ACL
+}
+
+// recordSetOwners is implemented by zones that know
+// which RecordSets contributed an RRset.
+type recordSetOwners interface {
+	Owners(zone, name string, qtype uint16) []types.NamespacedName
 }
 
 type route42plugin struct {
-	Namespace string
-	Next      plugin.Handler
+	// Origins are the zones this plugin is authoritative for.
+	// Queries for these zones are answered with NotReadyRcode until synced.
+	Origins []string
+	// NotReadyRcode is returned for queries to Origins until synced.
+	NotReadyRcode int
+	// Namespaces to watch, empty means all namespaces.
+	Namespaces []string
+	// ZoneSelector restricts the Zones and ClusterZones served by this plugin.
+	ZoneSelector labels.Selector
+	// RecordSetSelector restricts the RecordSets served by this plugin.
+	RecordSetSelector labels.Selector
+	// Directory to load Zones and RecordSets from instead of Kubernetes.
+	Directory string
+	// Snapshot is a directory to persist rendered zones to.
+	// The snapshot is served after a restart until the first reconcile.
+	Snapshot string
+	// QueryLog logs sampled queries, if set.
+	QueryLog *queryLog
+	// RRL rate limits responses over UDP, if set.
+	RRL *rrl
+	// Aliases resolves the targets of ALIAS records.
+	Aliases *aliasResolver
+	Next    plugin.Handler
+
+	log      logr.Logger
+	zones    zones
+	snapshot *snapshot
+	// synced is set to 1 after the first successful reconcile.
+	synced int32
+
+	// zoneNames of the last update, to remove metrics of deleted zones.
+	zoneNames map[string]struct{}
 
-	log   logr.Logger
-	zones zones
+	// stop is closed to stop watching, done is closed after watching stopped.
+	stop, done chan struct{}
 }
 
-func newRoute42Plugin(namespace string) (*route42plugin, error) {
+func newRoute42Plugin(namespaces []string) (*route42plugin, error) {
 	route42 := &route42plugin{
-		Namespace: namespace,
-		log:       ctrl.Log.WithName("route42"),
+		Namespaces:    namespaces,
+		NotReadyRcode: dns.RcodeServerFailure,
+		log:           ctrl.Log.WithName("route42"),
 	}
 
+	servers, err := systemResolvers()
+	if err != nil {
+		route42.log.Error(err, "reading system resolvers for ALIAS records")
+	}
+	route42.Aliases = newAliasResolver(servers, route42.log.WithName("alias"))
 	return route42, nil
 }
 
@@ -71,27 +128,46 @@ func (p *route42plugin) Name() string { return pluginName }
 
 func (p *route42plugin) ServeDNS(
 	ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	start := time.Now()
 	state := request.Request{W: w, Req: r}
 	qname := state.Name()
 
 	log := p.log.WithValues("qname", qname, "qtype", state.Type())
 	log.V(1).Info("serving")
 
-	p.zones.RLock()
-	defer p.zones.RUnlock()
+	source := p.source()
+	if source == nil {
+		// Not synced yet, don't let queries for our zones leak to other plugins.
+		origin := plugin.Zones(p.Origins).Matches(qname)
+		if origin == "" {
+			return plugin.NextOrFailure(p.Name(), p.Next, ctx, w, r)
+		}
+		log.V(1).Info("not ready")
+		p.observe(ctx, state, origin, p.NotReadyRcode, nil, start)
+		return p.rateLimitError(ctx, state, origin, p.NotReadyRcode)
+	}
+	source.RLock()
+	defer source.RUnlock()
 
 	// check if we are managing the zone for the request
-	zones := p.zones.Zones()
+	zones := source.Zones()
 	zoneName := plugin.Zones(zones).Matches(qname)
 	if zoneName == "" {
 		log.WithValues("zones", zones).Info("zone not managed")
 		return plugin.NextOrFailure(p.Name(), p.Next, ctx, w, r)
 	}
 
+	if !source.ACL(zoneName).Allowed(net.ParseIP(state.IP())) {
+		log.V(1).Info("refused by allowQuery", "client", state.IP())
+		p.observe(ctx, state, zoneName, dns.RcodeRefused, nil, start)
+		return p.rateLimitError(ctx, state, zoneName, dns.RcodeRefused)
+	}
+
 	// get the zone object
-	zone, ok := p.zones.Zone(zoneName)
+	zone, ok := source.Zone(zoneName)
 	if !ok {
-		return dns.RcodeServerFailure, nil
+		p.observe(ctx, state, zoneName, dns.RcodeServerFailure, nil, start)
+		return p.rateLimitError(ctx, state, zoneName, dns.RcodeServerFailure)
 	}
 
 	m := &dns.Msg{}
@@ -108,26 +184,221 @@ func (p *route42plugin) ServeDNS(
 	case file.Delegation:
 		m.Authoritative = false
 	case file.ServerFailure:
-		return dns.RcodeServerFailure, nil
+		p.observe(ctx, state, zoneName, dns.RcodeServerFailure, nil, start)
+		return p.rateLimitError(ctx, state, zoneName, dns.RcodeServerFailure)
+	}
+
+	var owners []types.NamespacedName
+	if o, ok := source.(recordSetOwners); ok && p.QueryLog != nil && len(m.Answer) > 0 {
+		hdr := m.Answer[0].Header()
+		owners = o.Owners(zoneName, hdr.Name, hdr.Rrtype)
 	}
+	p.observe(ctx, state, zoneName, m.Rcode, owners, start)
 
+	if action, err := p.rateLimit(ctx, state, m, zoneName); action != rrlSend {
+		return dns.RcodeSuccess, err
+	}
 	return dns.RcodeSuccess, w.WriteMsg(m)
 }
 
-func (p *route42plugin) Run() error {
+// rateLimit applies response rate limiting to the response m.
+// Unless the response may be sent, it was either dropped or a truncated
+// reply was written instead.
+// Responses over TCP are never limited, as their source is verified.
+func (p *route42plugin) rateLimit(
+	ctx context.Context, state request.Request, m *dns.Msg, zone string,
+) (rrlAction, error) {
+	if p.RRL == nil || state.Proto() == "tcp" {
+		return rrlSend, nil
+	}
+	class, name := classify(m, zone)
+	action := p.RRL.Account(net.ParseIP(state.IP()), class, name)
+	observeRateLimit(ctx, class, action)
+	if action == rrlSlip {
+		return action, state.W.WriteMsg(truncated(state.Req, m))
+	}
+	return action, nil
+}
+
+// rateLimitError applies response rate limiting to an error response,
+// that is written by the server if it may be sent.
+func (p *route42plugin) rateLimitError(
+	ctx context.Context, state request.Request, zone string, rcode int) (int, error) {
+	m := &dns.Msg{}
+	m.SetRcode(state.Req, rcode)
+	if action, err := p.rateLimit(ctx, state, m, zone); action != rrlSend {
+		return dns.RcodeSuccess, err
+	}
+	return rcode, nil
+}
+
+// observe records metrics for a query answered by the plugin
+// and writes it to the query log, if sampled.
+func (p *route42plugin) observe(
+	ctx context.Context, state request.Request, zone string, rcode int,
+	owners []types.NamespacedName, start time.Time,
+) {
+	observeQuery(ctx, state, zone, rcode, start)
+	if p.QueryLog != nil && p.QueryLog.Sample(zone) {
+		p.QueryLog.Log(state, zone, rcode, owners, start)
+	}
+}
+
+// Ready implements the ready.Readiness interface.
+// The plugin is ready after the caches have synced
+// and the first reconcile has completed,
+// or while serving the zones of a snapshot until then.
+func (p *route42plugin) Ready() bool {
+	if p.isSynced() {
+		return true
+	}
+	if p.snapshot == nil {
+		return false
+	}
+	p.snapshot.RLock()
+	defer p.snapshot.RUnlock()
+	return len(p.snapshot.Zones()) > 0
+}
+
+// isSynced returns true after the first reconcile has completed.
+func (p *route42plugin) isSynced() bool {
+	return atomic.LoadInt32(&p.synced) == 1
+}
+
+// source returns the zones to serve from,
+// which is the snapshot until the first reconcile has completed.
+// Returns nil if there is nothing to serve yet.
+func (p *route42plugin) source() zones {
+	if p.isSynced() {
+		return p.zones
+	}
+	if p.snapshot != nil {
+		return p.snapshot
+	}
+	return nil
+}
+
+// loadSnapshot loads the zones of the last run, if a snapshot is configured.
+// Must be called before Start.
+func (p *route42plugin) loadSnapshot() error {
+	if p.Snapshot == "" {
+		return nil
+	}
+	p.snapshot = newSnapshot(p.Snapshot)
+	if err := p.snapshot.Load(); err != nil {
+		return fmt.Errorf("loading snapshot: %w", err)
+	}
+	snapshotStale.Set(1)
+	p.log.Info("serving snapshot", "directory", p.Snapshot, "zones", p.snapshot.Zones())
+	return nil
+}
+
+// onUpdate is called after every successful reconcile.
+func (p *route42plugin) onUpdate(update controllers.Update) {
+	if atomic.CompareAndSwapInt32(&p.synced, 0, 1) {
+		snapshotStale.Set(0)
+	}
+	p.observeZones(update.Records)
+	if p.snapshot == nil {
+		return
+	}
+	if err := p.snapshot.Save(update); err != nil {
+		p.log.Error(err, "saving snapshot")
+	}
+}
+
+// selectors returns the label selectors to apply to the watched objects.
+func (p *route42plugin) selectors() map[schema.GroupKind]labels.Selector {
+	selectors := map[schema.GroupKind]labels.Selector{}
+	if p.ZoneSelector != nil {
+		for _, kind := range []string{"Zone", "ClusterZone"} {
+			gk := schema.GroupKind{Group: route42v1alpha1.GroupVersion.Group, Kind: kind}
+			selectors[gk] = p.ZoneSelector
+		}
+	}
+	if p.RecordSetSelector != nil {
+		gk := schema.GroupKind{Group: route42v1alpha1.GroupVersion.Group, Kind: "RecordSet"}
+		selectors[gk] = p.RecordSetSelector
+	}
+	return selectors
+}
+
+// heartbeatNamespace returns the namespace for the heartbeat Lease,
+// unless overridden by $POD_NAMESPACE.
+func (p *route42plugin) heartbeatNamespace() string {
+	if len(p.Namespaces) > 0 {
+		return p.Namespaces[0]
+	}
+	return "default"
+}
+
+// Start starts watching Zones and RecordSets.
+// It is registered as caddy OnStartup hook.
+func (p *route42plugin) Start() error {
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+
+	if p.Directory != "" {
+		return p.runFiles(p.stop, p.done)
+	}
+
 	cfg, err := ctrl.GetConfig()
 	if err != nil {
+		close(p.done)
 		return fmt.Errorf("creating config: %w", err)
 	}
+	go p.runManager(cfg)
+	return nil
+}
+
+// Stop stops the manager or directory watch and waits for it to exit.
+// It is registered as caddy OnShutdown hook, so a Corefile reload
+// does not leave the previous instance running.
+func (p *route42plugin) Stop() error {
+	if p.stop == nil {
+		return nil
+	}
+	close(p.stop)
+	<-p.done
+	p.stop = nil
+	return nil
+}
+
+// runManager creates and runs the manager until p.stop is closed.
+// The API server may be unavailable while starting,
+// so creating the manager is retried, while the snapshot is served.
+func (p *route42plugin) runManager(cfg *rest.Config) {
+	defer close(p.done)
+
+	var mgr manager.Manager
+	err := wait.PollImmediateUntil(syncRetryInterval, func() (bool, error) {
+		var err error
+		if mgr, err = p.newManager(cfg); err != nil {
+			p.log.Error(err, "creating manager")
+			return false, nil
+		}
+		return true, nil
+	}, p.stop)
+	if err != nil {
+		// stopped before the manager could be created
+		return
+	}
+
+	if err := mgr.Start(p.stop); err != nil {
+		p.log.Error(err, "running manager")
+	}
+}
+
+func (p *route42plugin) newManager(cfg *rest.Config) (manager.Manager, error) {
 	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: "0",
 		LeaderElection:     false,
-		Namespace:          p.Namespace,
+		NewCache:           newCacheFunc(p.Namespaces, p.selectors()),
 		Port:               0,
 	})
 	if err != nil {
-		return fmt.Errorf("creating manager: %w", err)
+		return nil, fmt.Errorf("creating manager: %w", err)
 	}
 
 	// controllers
@@ -135,17 +406,44 @@ func (p *route42plugin) Run() error {
 		mgr.GetClient(),
 		ctrl.Log.WithName("controllers").WithName("Zone"),
 	)
-	p.zones = zoneReconciler
+	zoneReconciler.OnUpdate(p.onUpdate)
 	if err = zoneReconciler.SetupWithManager(mgr); err != nil {
-		return fmt.Errorf("creating Zone controller: %w", err)
+		return nil, fmt.Errorf("creating Zone controller: %w", err)
 	}
 
-	var stop chan struct{}
-	go func() {
-		if err := mgr.Start(stop); err != nil {
-			panic(err)
-		}
-	}()
+	// rebuild the zones through the controller,
+	// when ALIAS targets resolve to new addresses
+	zoneReconciler.SetAliasResolver(p.Aliases)
+	p.Aliases.OnChange(zoneReconciler.Requeue)
+	if err := mgr.Add(p.Aliases); err != nil {
+		return nil, fmt.Errorf("adding ALIAS resolver: %w", err)
+	}
 
-	return nil
+	// publish the served zones for the manager
+	hb, err := newHeartbeat(mgr.GetClient(), mgr.GetAPIReader(),
+		p.heartbeatNamespace(), p.log.WithName("heartbeat"))
+	if err != nil {
+		return nil, fmt.Errorf("creating heartbeat: %w", err)
+	}
+	zoneReconciler.OnUpdate(hb.Update)
+	if err := mgr.Add(hb); err != nil {
+		return nil, fmt.Errorf("adding heartbeat: %w", err)
+	}
+
+	// Runnables are started after the caches have synced.
+	// Reconcile once, so the zones are marked synced even without any objects.
+	if err := mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		return wait.PollImmediateUntil(syncRetryInterval, func() (bool, error) {
+			if _, err := zoneReconciler.Reconcile(ctrl.Request{}); err != nil {
+				p.log.Error(err, "initial reconcile")
+				return false, nil
+			}
+			return true, nil
+		}, stop)
+	})); err != nil {
+		return nil, fmt.Errorf("adding initial reconcile: %w", err)
+	}
+
+	p.zones = zoneReconciler
+	return mgr, nil
 }
@@ -0,0 +1,319 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route42plugin
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	coretest "github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/thetechnick/route42/coredns/controllers"
+)
+
+const testManifests = `
+apiVersion: route42.thetechnick.ninja/v1alpha1
+kind: Zone
+metadata:
+  name: example.com
+  namespace: default
+zone:
+  soa:
+    master: ns.example.com.
+    admin: admin.example.com.
+    serial: 1
+---
+apiVersion: route42.thetechnick.ninja/v1alpha1
+kind: RecordSet
+metadata:
+  name: www
+  namespace: default
+record:
+  dnsName: www.example.com.
+  ttl: 60s
+  a: ["192.0.2.1"]
+`
+
+// newTestPlugin starts a plugin serving the given manifests
+// from a temporary directory. The configure funcs may change the plugin
+// before it is started. The returned func stops the plugin.
+func newTestPlugin(
+	t *testing.T, manifests string, configure ...func(*route42plugin),
+) (*route42plugin, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "route42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(
+		filepath.Join(dir, "manifests.yaml"), []byte(manifests), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	p, err := newRoute42Plugin(nil)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	p.Origins = []string{"example.com."}
+	p.Directory = dir
+	for _, fn := range configure {
+		fn(p)
+	}
+	if err := p.Start(); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return p, func() {
+		_ = p.Stop()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestConcurrentReconcile(t *testing.T) {
+	snapshotDir, err := ioutil.TempDir("", "route42-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(snapshotDir)
+
+	p, stop := newTestPlugin(t, testManifests, func(p *route42plugin) {
+		p.Snapshot = snapshotDir
+		if err := p.loadSnapshot(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	defer stop()
+
+	// reconciles of the directory watch, ALIAS changes and the initial sync
+	// may overlap, run with -race
+	r := p.zones.(*controllers.ZoneReconciler)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.Reconcile(ctrl.Request{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	files, err := filepath.Glob(filepath.Join(snapshotDir, "*"+snapshotExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || filepath.Base(files[0]) != "example.com.zone" {
+		t.Errorf("expected the snapshot of example.com, got %v", files)
+	}
+}
+
+func TestReadyWithSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "route42-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p, err := newRoute42Plugin(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Snapshot = dir
+	if err := p.loadSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if p.Ready() {
+		t.Error("expected not ready with an empty snapshot")
+	}
+
+	// a pod serving its snapshot must stay in the Service
+	zone := "$ORIGIN example.com.\n" +
+		"@ 60 IN SOA ns.example.com. admin.example.com. 1 7200 3600 1209600 60\n" +
+		"www 60 IN A 192.0.2.1\n"
+	if err := ioutil.WriteFile(
+		filepath.Join(dir, "example.com.zone"), []byte(zone), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.loadSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if !p.Ready() {
+		t.Error("expected ready while serving the snapshot")
+	}
+}
+
+func TestAllowQuery(t *testing.T) {
+	manifests := strings.Replace(testManifests,
+		"zone:\n", "zone:\n  allowQuery: [\"10.0.0.0/8\", \"invalid\"]\n", 1)
+	p, stop := newTestPlugin(t, manifests)
+	defer stop()
+
+	tests := []struct {
+		client string
+		rcode  int
+	}{
+		{client: "10.1.2.3", rcode: dns.RcodeSuccess},
+		{client: "192.0.2.10", rcode: dns.RcodeRefused},
+	}
+	for _, test := range tests {
+		m := &dns.Msg{}
+		m.SetQuestion("www.example.com.", dns.TypeA)
+		rec := dnstest.NewRecorder(&coretest.ResponseWriter{RemoteIP: test.client})
+		rcode, err := p.ServeDNS(context.Background(), rec, m)
+		if err != nil {
+			t.Fatalf("ServeDNS: %v", err)
+		}
+		if rec.Msg != nil {
+			rcode = rec.Msg.Rcode
+		}
+		if rcode != test.rcode {
+			t.Errorf("%s: got %s, want %s",
+				test.client, dns.RcodeToString[rcode], dns.RcodeToString[test.rcode])
+		}
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "route42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	manifestsDir := filepath.Join(dir, "manifests")
+	if err := os.Mkdir(manifestsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// the API server is unreachable, so the manager is never created
+	kubeconfig := filepath.Join(dir, "kubeconfig")
+	if err := ioutil.WriteFile(kubeconfig, []byte(`
+apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: http://127.0.0.1:1
+contexts:
+- name: test
+  context:
+    cluster: test
+current-context: test
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("KUBECONFIG", os.Getenv("KUBECONFIG"))
+	os.Setenv("KUBECONFIG", kubeconfig)
+
+	tests := []struct {
+		name      string
+		directory string
+		// manifests written before each start
+		manifests []string
+		wantErr   bool
+		// zones served after each start
+		wantZones [][]string
+	}{
+		{
+			name:      "directory",
+			directory: manifestsDir,
+			manifests: []string{testManifests, testManifests + `
+---
+apiVersion: route42.thetechnick.ninja/v1alpha1
+kind: Zone
+metadata:
+  name: example.org
+  namespace: default
+zone:
+  soa:
+    master: ns.example.org.
+    admin: admin.example.org.
+    serial: 1
+`},
+			wantZones: [][]string{
+				{"example.com."},
+				{"example.com.", "example.org."},
+			},
+		},
+		{
+			name:      "missing directory",
+			directory: filepath.Join(dir, "missing"),
+			wantErr:   true,
+		},
+		{
+			name: "unreachable API server",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := newRoute42Plugin(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.Directory = test.directory
+
+			// start twice, like a Corefile reload restarting the plugin
+			for i := 0; i < 2; i++ {
+				if i < len(test.manifests) {
+					if err := ioutil.WriteFile(filepath.Join(manifestsDir, "manifests.yaml"),
+						[]byte(test.manifests[i]), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				err := p.Start()
+				if (err != nil) != test.wantErr {
+					t.Errorf("start %d: got error %v, want error %v", i, err, test.wantErr)
+				}
+				if i < len(test.wantZones) {
+					if zones := p.zones.Zones(); !reflect.DeepEqual(zones, test.wantZones[i]) {
+						t.Errorf("start %d: got zones %v, want %v", i, zones, test.wantZones[i])
+					}
+				}
+				stopWithin(t, p, 5*time.Second)
+			}
+			// stopping again is a no-op
+			stopWithin(t, p, time.Second)
+		})
+	}
+}
+
+func stopWithin(t *testing.T, p *route42plugin, timeout time.Duration) {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- p.Stop() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("stop: %v", err)
+		}
+	case <-time.After(timeout):
+		t.Fatalf("stop did not return within %s", timeout)
+	}
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route42plugin
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// responseClass groups responses for rate limiting,
+// following the response kinds of BIND's RRL.
+type responseClass int
+
+const (
+	classResponse responseClass = iota
+	classNoData
+	classNXDomain
+	classReferral
+	classError
+	numResponseClasses
+)
+
+var responseClassNames = [numResponseClasses]string{
+	"responses", "nodata", "nxdomains", "referrals", "errors",
+}
+
+// rrlAction is the outcome of rate limiting a response.
+type rrlAction int
+
+const (
+	rrlSend rrlAction = iota
+	rrlDrop
+	rrlSlip
+)
+
+// rrl implements Response Rate Limiting.
+// Responses are accounted per client prefix, response class and name.
+// When the rate of a class is exceeded, responses are dropped and every
+// slip-th limited response is answered truncated instead, so legitimate
+// clients can retry over TCP.
+type rrl struct {
+	// ratePerSecond per response class, 0 disables limiting for the class.
+	ratePerSecond [numResponseClasses]float64
+	// ipv4Prefix and ipv6Prefix are the prefix lengths clients are grouped by.
+	ipv4Prefix, ipv6Prefix int
+	// slip is the ratio of limited responses answered truncated, 0 drops all.
+	slip int
+	// window is the number of seconds over which the rate is averaged.
+	window int
+	// exempt clients are never limited.
+	exempt []*net.IPNet
+
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[rrlKey]*rrlBucket
+	lastSweep time.Time
+}
+
+type rrlKey struct {
+	prefix string
+	class  responseClass
+	name   string
+}
+
+type rrlBucket struct {
+	balance float64
+	last    time.Time
+	limited int
+}
+
+func newRRL(ratePerSecond float64) *rrl {
+	r := &rrl{
+		ipv4Prefix: 24,
+		ipv6Prefix: 56,
+		slip:       2,
+		window:     15,
+		now:        time.Now,
+		buckets:    map[rrlKey]*rrlBucket{},
+	}
+	for class := range r.ratePerSecond {
+		r.ratePerSecond[class] = ratePerSecond
+	}
+	return r
+}
+
+// classify returns the response class and the name to account the response to.
+// Positive responses are accounted per qname and qtype, negative responses
+// and errors per zone, so random subdomains share a single limit.
+func classify(m *dns.Msg, zone string) (responseClass, string) {
+	var qname, qtype string
+	if len(m.Question) > 0 {
+		qname = strings.ToLower(m.Question[0].Name)
+		qtype = dns.TypeToString[m.Question[0].Qtype]
+	}
+	switch {
+	case m.Rcode == dns.RcodeNameError:
+		return classNXDomain, zone
+	case m.Rcode != dns.RcodeSuccess:
+		return classError, zone
+	case len(m.Answer) > 0:
+		return classResponse, qname + "/" + qtype
+	case !m.Authoritative && len(m.Ns) > 0:
+		return classReferral, zone
+	default:
+		return classNoData, qname
+	}
+}
+
+// Account records a response to client and decides how to send it.
+func (r *rrl) Account(client net.IP, class responseClass, name string) rrlAction {
+	rate := r.ratePerSecond[class]
+	if rate <= 0 || r.isExempt(client) {
+		return rrlSend
+	}
+
+	key := rrlKey{prefix: r.clientPrefix(client), class: class, name: name}
+	now := r.now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sweep(now)
+
+	b, ok := r.buckets[key]
+	if !ok {
+		b = &rrlBucket{balance: rate, last: now}
+		r.buckets[key] = b
+	}
+
+	// refill, limited to one second of responses
+	b.balance += now.Sub(b.last).Seconds() * rate
+	if b.balance > rate {
+		b.balance = rate
+	}
+	b.last = now
+
+	// debit every response, but go no further into debt than the window
+	b.balance--
+	if min := -rate * float64(r.window); b.balance < min {
+		b.balance = min
+	}
+	if b.balance >= 0 {
+		b.limited = 0
+		return rrlSend
+	}
+
+	b.limited++
+	if r.slip > 0 && b.limited%r.slip == 0 {
+		return rrlSlip
+	}
+	return rrlDrop
+}
+
+func (r *rrl) isExempt(client net.IP) bool {
+	for _, n := range r.exempt {
+		if n.Contains(client) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *rrl) clientPrefix(client net.IP) string {
+	if ip4 := client.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(r.ipv4Prefix, 32)).String()
+	}
+	return client.Mask(net.CIDRMask(r.ipv6Prefix, 128)).String()
+}
+
+// sweep removes buckets that have fully refilled, at most once per second.
+func (r *rrl) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < time.Second {
+		return
+	}
+	r.lastSweep = now
+	for key, b := range r.buckets {
+		rate := r.ratePerSecond[key.class]
+		if b.balance+now.Sub(b.last).Seconds()*rate >= rate {
+			delete(r.buckets, key)
+		}
+	}
+}
+
+// truncated returns an empty, truncated reply to req with the rcode of
+// the response m, telling the client to retry over TCP.
+func truncated(req, m *dns.Msg) *dns.Msg {
+	t := &dns.Msg{}
+	t.SetReply(req)
+	t.Truncated = true
+	t.Authoritative = m.Authoritative
+	t.Rcode = m.Rcode
+	return t
+}
@@ -0,0 +1,270 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route42plugin
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+)
+
+// outcomes of a query
+const (
+	answered = "answered"
+	nxdomain = "nxdomain"
+	dropped  = "dropped"
+	slipped  = "slipped"
+)
+
+type rrlQuery struct {
+	client string
+	qname  string
+	tcp    bool
+	// advance the clock before sending the query
+	advance time.Duration
+	want    string
+}
+
+// repeat returns n identical queries.
+func repeat(n int, q rrlQuery) []rrlQuery {
+	qs := make([]rrlQuery, n)
+	for i := range qs {
+		qs[i] = q
+	}
+	return qs
+}
+
+func queries(qs ...[]rrlQuery) []rrlQuery {
+	var all []rrlQuery
+	for _, q := range qs {
+		all = append(all, q...)
+	}
+	return all
+}
+
+func TestRRL(t *testing.T) {
+	const (
+		client  = "198.51.100.10"
+		www     = "www.example.com."
+		missing = "missing.example.com."
+	)
+
+	tests := []struct {
+		name    string
+		rrl     func() *rrl
+		queries []rrlQuery
+	}{
+		{
+			name: "within limit",
+			rrl:  func() *rrl { return newRRL(5) },
+			queries: repeat(5,
+				rrlQuery{client: client, qname: www, want: answered}),
+		},
+		{
+			name: "slips every second limited response",
+			rrl:  func() *rrl { return newRRL(2) },
+			queries: queries(
+				repeat(2, rrlQuery{client: client, qname: www, want: answered}),
+				[]rrlQuery{
+					{client: client, qname: www, want: dropped},
+					{client: client, qname: www, want: slipped},
+					{client: client, qname: www, want: dropped},
+					{client: client, qname: www, want: slipped},
+				},
+			),
+		},
+		{
+			name: "slip 0 drops all limited responses",
+			rrl: func() *rrl {
+				r := newRRL(1)
+				r.slip = 0
+				return r
+			},
+			queries: queries(
+				repeat(1, rrlQuery{client: client, qname: www, want: answered}),
+				repeat(4, rrlQuery{client: client, qname: www, want: dropped}),
+			),
+		},
+		{
+			name: "slip 1 truncates all limited responses",
+			rrl: func() *rrl {
+				r := newRRL(1)
+				r.slip = 1
+				return r
+			},
+			queries: queries(
+				repeat(1, rrlQuery{client: client, qname: www, want: answered}),
+				repeat(3, rrlQuery{client: client, qname: www, want: slipped}),
+			),
+		},
+		{
+			name: "rate refills over time",
+			rrl: func() *rrl {
+				r := newRRL(2)
+				r.slip = 0
+				return r
+			},
+			queries: []rrlQuery{
+				{client: client, qname: www, want: answered},
+				{client: client, qname: www, want: answered},
+				{client: client, qname: www, want: dropped},
+				// limited responses are accounted as well, so only one refilled
+				{client: client, qname: www, advance: time.Second, want: answered},
+				{client: client, qname: www, want: dropped},
+				{client: client, qname: www, advance: 2 * time.Second, want: answered},
+				{client: client, qname: www, want: answered},
+			},
+		},
+		{
+			name: "window limits the debt of a flood",
+			rrl: func() *rrl {
+				r := newRRL(1)
+				r.slip = 0
+				r.window = 2
+				return r
+			},
+			queries: queries(
+				repeat(1, rrlQuery{client: client, qname: www, want: answered}),
+				repeat(10, rrlQuery{client: client, qname: www, want: dropped}),
+				[]rrlQuery{
+					{client: client, qname: www, advance: 2 * time.Second, want: dropped},
+					{client: client, qname: www, advance: 2 * time.Second, want: answered},
+				},
+			),
+		},
+		{
+			name: "clients in the same prefix share a limit",
+			rrl:  func() *rrl { return newRRL(2) },
+			queries: []rrlQuery{
+				{client: "198.51.100.1", qname: www, want: answered},
+				{client: "198.51.100.2", qname: www, want: answered},
+				{client: "198.51.100.3", qname: www, want: dropped},
+				{client: "198.51.101.1", qname: www, want: answered},
+			},
+		},
+		{
+			name: "IPv6 clients are grouped by the IPv6 prefix",
+			rrl:  func() *rrl { return newRRL(1) },
+			queries: []rrlQuery{
+				{client: "2001:db8:0:1::1", qname: www, want: answered},
+				{client: "2001:db8:0:1::2", qname: www, want: dropped},
+				{client: "2001:db8:0:100::1", qname: www, want: answered},
+			},
+		},
+		{
+			name: "exempt clients are not limited",
+			rrl: func() *rrl {
+				r := newRRL(1)
+				_, n, _ := net.ParseCIDR("198.51.100.0/24")
+				r.exempt = []*net.IPNet{n}
+				return r
+			},
+			queries: queries(
+				repeat(5, rrlQuery{client: client, qname: www, want: answered}),
+				repeat(1, rrlQuery{client: "203.0.113.1", qname: www, want: answered}),
+				repeat(1, rrlQuery{client: "203.0.113.1", qname: www, want: dropped}),
+			),
+		},
+		{
+			name: "TCP is not limited",
+			rrl:  func() *rrl { return newRRL(1) },
+			queries: queries(
+				repeat(1, rrlQuery{client: client, qname: www, want: answered}),
+				repeat(3, rrlQuery{client: client, qname: www, tcp: true, want: answered}),
+			),
+		},
+		{
+			name: "NXDOMAIN for random names is limited per zone",
+			rrl:  func() *rrl { return newRRL(2) },
+			queries: []rrlQuery{
+				{client: client, qname: "a." + missing, want: nxdomain},
+				{client: client, qname: "b." + missing, want: nxdomain},
+				{client: client, qname: "c." + missing, want: dropped},
+				// positive answers are accounted separately
+				{client: client, qname: www, want: answered},
+			},
+		},
+		{
+			name: "per class rates",
+			rrl: func() *rrl {
+				r := newRRL(0)
+				r.ratePerSecond[classNXDomain] = 1
+				return r
+			},
+			queries: queries(
+				repeat(5, rrlQuery{client: client, qname: www, want: answered}),
+				[]rrlQuery{
+					{client: client, qname: missing, want: nxdomain},
+					{client: client, qname: missing, want: dropped},
+					{client: client, qname: missing, want: slipped},
+				},
+			),
+		},
+	}
+
+	p, stop := newTestPlugin(t, testManifests)
+	defer stop()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now := time.Date(2019, 11, 1, 0, 0, 0, 0, time.UTC)
+			p.RRL = test.rrl()
+			p.RRL.now = func() time.Time { return now }
+			defer func() { p.RRL = nil }()
+
+			for i, q := range test.queries {
+				now = now.Add(q.advance)
+				if got := serveRRLQuery(t, p, q); got != q.want {
+					t.Errorf("query %d for %s from %s: got %s, want %s",
+						i, q.qname, q.client, got, q.want)
+				}
+			}
+		})
+	}
+}
+
+func serveRRLQuery(t *testing.T, p *route42plugin, q rrlQuery) string {
+	t.Helper()
+
+	m := &dns.Msg{}
+	m.SetQuestion(q.qname, dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{RemoteIP: q.client, TCP: q.tcp})
+	if _, err := p.ServeDNS(context.Background(), rec, m); err != nil {
+		t.Fatalf("ServeDNS: %v", err)
+	}
+
+	switch {
+	case rec.Msg == nil:
+		return dropped
+	case rec.Msg.Truncated:
+		if len(rec.Msg.Answer) > 0 {
+			t.Errorf("truncated response contains answers")
+		}
+		return slipped
+	case rec.Msg.Rcode == dns.RcodeNameError:
+		return nxdomain
+	case len(rec.Msg.Answer) > 0:
+		return answered
+	}
+	t.Fatalf("unexpected response: %v", rec.Msg)
+	return ""
+}
@@ -17,11 +17,19 @@ limitations under the License.
 package route42plugin
 
 import (
+	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/caddyserver/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/miekg/dns"
+	"k8s.io/apimachinery/pkg/labels"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
@@ -34,35 +42,195 @@ func setup(c *caddy.Controller) error {
 	}))
 
 	for c.Next() {
-		var namespace string
-		if c.NextBlock() {
+		var origins []string
+		if args := c.RemainingArgs(); len(args) > 0 {
+			for _, arg := range args {
+				origins = append(origins, plugin.Host(arg).Normalize())
+			}
+		} else {
+			// the root zone of a catch-all server block is not gated,
+			// that would fail the queries of all following plugins until synced
+			for _, key := range c.ServerBlockKeys {
+				if origin := plugin.Host(key).Normalize(); origin != "." {
+					origins = append(origins, origin)
+				}
+			}
+		}
+		if len(origins) == 0 {
+			// queries for unsynced zones would be passed on to the next plugin,
+			// e.g. forward, leaking internal names to upstream resolvers
+			return plugin.Error(pluginName, fmt.Errorf(
+				"zones to serve must be given as arguments in a catch-all server block"))
+		}
+
+		var (
+			namespaces                      []string
+			directory, snapshotDir          string
+			zoneSelector, recordSetSelector labels.Selector
+			notReadyRcode                   = dns.RcodeServerFailure
+			queryLogArgs                    []string
+			rrlArgs                         = map[string][]string{}
+			querySampling                   = map[string]float64{}
+			aliasResolvers                  []string
+			aliasRefresh                    time.Duration
+			err                             error
+		)
+		for c.NextBlock() {
 			switch c.Val() {
 			case "namespace":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return c.ArgErr()
+				}
+				namespaces = append(namespaces, args...)
+
+			case "zone_selector":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return c.ArgErr()
+				}
+				if zoneSelector, err = parseSelector(strings.Join(args, " ")); err != nil {
+					return plugin.Error(pluginName, err)
+				}
+
+			case "recordset_selector":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return c.ArgErr()
+				}
+				if recordSetSelector, err = parseSelector(strings.Join(args, " ")); err != nil {
+					return plugin.Error(pluginName, err)
+				}
+
+			case "directory":
 				if !c.NextArg() {
 					return c.ArgErr()
 				}
-				namespace = c.Val()
+				directory = c.Val()
 
-			default:
-				if c.Val() != "}" {
-					return c.Errf("unknown property '%s'", c.Val())
+			case "not_ready":
+				if !c.NextArg() {
+					return c.ArgErr()
+				}
+				switch rcode := strings.ToUpper(c.Val()); rcode {
+				case "SERVFAIL", "REFUSED":
+					notReadyRcode = dns.StringToRcode[rcode]
+				default:
+					return c.Errf("not_ready must be SERVFAIL or REFUSED, got '%s'", c.Val())
+				}
+
+			case "query_log":
+				queryLogArgs = c.RemainingArgs()
+				if len(queryLogArgs) == 0 || len(queryLogArgs) > 3 {
+					return c.ArgErr()
+				}
+
+			case "query_log_sample":
+				args := c.RemainingArgs()
+				zone := "."
+				switch len(args) {
+				case 1:
+				case 2:
+					zone = args[0]
+				default:
+					return c.ArgErr()
+				}
+				rate, err := strconv.ParseFloat(args[len(args)-1], 64)
+				if err != nil || rate < 0 || rate > 1 {
+					return c.Errf("query_log_sample rate must be between 0 and 1, got '%s'", args[len(args)-1])
+				}
+				querySampling[zone] = rate
+
+			case "rrl", "rrl_rate", "rrl_prefix", "rrl_slip", "rrl_window", "rrl_exempt":
+				property := c.Val()
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return c.ArgErr()
+				}
+				rrlArgs[property] = append(rrlArgs[property], args...)
+
+			case "snapshot":
+				if !c.NextArg() {
+					return c.ArgErr()
+				}
+				snapshotDir = c.Val()
+
+			case "alias_resolver":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return c.ArgErr()
 				}
+				if aliasResolvers, err = parseAliasResolvers(args); err != nil {
+					return c.Err(err.Error())
+				}
+
+			case "alias_refresh":
+				if !c.NextArg() {
+					return c.ArgErr()
+				}
+				if aliasRefresh, err = time.ParseDuration(c.Val()); err != nil || aliasRefresh < aliasMinRefresh {
+					return c.Errf("alias_refresh must be a duration of at least %s, got '%s'", aliasMinRefresh, c.Val())
+				}
+
+			default:
+				return c.Errf("unknown property '%s'", c.Val())
 			}
 		}
 
-		if namespace == "" {
-			namespace = os.Getenv("ROUTE42_NAMESPACE")
+		if len(namespaces) == 0 {
+			if env := os.Getenv("ROUTE42_NAMESPACE"); env != "" {
+				namespaces = strings.Split(env, ",")
+			}
 		}
-		r, err := newRoute42Plugin(namespace)
+		r, err := newRoute42Plugin(namespaces)
 		if err != nil {
 			return plugin.Error(pluginName, err)
 		}
+		r.ZoneSelector = zoneSelector
+		r.RecordSetSelector = recordSetSelector
+		r.Directory = directory
+		r.Snapshot = snapshotDir
+		r.Origins = origins
+		r.NotReadyRcode = notReadyRcode
+		if len(aliasResolvers) > 0 {
+			r.Aliases.Servers = aliasResolvers
+		}
+		if aliasRefresh > 0 {
+			r.Aliases.MaxRefresh = aliasRefresh
+		}
+		if err := r.loadSnapshot(); err != nil {
+			return plugin.Error(pluginName, err)
+		}
+		if len(rrlArgs) > 0 {
+			rl, err := parseRRL(rrlArgs)
+			if err != nil {
+				return c.Err(err.Error())
+			}
+			r.RRL = rl
+		}
+		if len(queryLogArgs) > 0 {
+			ql, err := parseQueryLog(queryLogArgs)
+			if err != nil {
+				return c.Err(err.Error())
+			}
+			for zone, rate := range querySampling {
+				ql.SetSampling(zone, rate)
+			}
+			r.QueryLog = ql
+			c.OnShutdown(ql.Close)
+		}
 
-		go func() {
-			if err := r.Run(); err != nil {
-				panic(err)
+		c.OnStartup(func() error {
+			metrics.MustRegister(c, collectors()...)
+			return nil
+		})
+		c.OnStartup(func() error {
+			if err := r.Start(); err != nil {
+				return plugin.Error(pluginName, err)
 			}
-		}()
+			return nil
+		})
+		c.OnShutdown(r.Stop)
 		dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
 			r.Next = next
 			return r
@@ -70,3 +238,117 @@ func setup(c *caddy.Controller) error {
 	}
 	return nil
 }
+
+// parseQueryLog creates the query log from the arguments of the query_log
+// property: stdout or FILE [MAX_SIZE_MB [MAX_BACKUPS]].
+func parseQueryLog(args []string) (*queryLog, error) {
+	var (
+		maxSizeMB  = 100
+		maxBackups = 5
+		err        error
+	)
+	if len(args) > 1 {
+		if args[0] == "stdout" {
+			return nil, fmt.Errorf("query_log stdout does not support rotation")
+		}
+		if maxSizeMB, err = strconv.Atoi(args[1]); err != nil || maxSizeMB <= 0 {
+			return nil, fmt.Errorf("query_log max size must be a positive number of megabytes, got '%s'", args[1])
+		}
+	}
+	if len(args) > 2 {
+		if maxBackups, err = strconv.Atoi(args[2]); err != nil || maxBackups < 0 {
+			return nil, fmt.Errorf("query_log max backups must not be negative, got '%s'", args[2])
+		}
+	}
+	ql, err := newQueryLog(args[0], int64(maxSizeMB)<<20, maxBackups)
+	if err != nil {
+		return nil, fmt.Errorf("opening query log: %w", err)
+	}
+	return ql, nil
+}
+
+// parseRRL creates the response rate limiter from the arguments
+// of the rrl and rrl_* properties, keyed by property name.
+func parseRRL(args map[string][]string) (*rrl, error) {
+	rate, ok := args["rrl"]
+	if !ok {
+		return nil, fmt.Errorf("rrl properties require rrl RESPONSES_PER_SECOND")
+	}
+	if len(rate) != 1 {
+		return nil, fmt.Errorf("rrl takes a single rate")
+	}
+	perSecond, err := parseRate(rate[0])
+	if err != nil {
+		return nil, err
+	}
+	rl := newRRL(perSecond)
+
+	classRates := args["rrl_rate"]
+	if len(classRates)%2 != 0 {
+		return nil, fmt.Errorf("rrl_rate takes a response class and a rate")
+	}
+	for i := 0; i < len(classRates); i += 2 {
+		class := -1
+		for c, name := range responseClassNames {
+			if name == classRates[i] {
+				class = c
+			}
+		}
+		if class < 0 {
+			return nil, fmt.Errorf("unknown rrl response class '%s', must be one of %s",
+				classRates[i], strings.Join(responseClassNames[:], ", "))
+		}
+		if rl.ratePerSecond[class], err = parseRate(classRates[i+1]); err != nil {
+			return nil, err
+		}
+	}
+
+	if prefix, ok := args["rrl_prefix"]; ok {
+		if len(prefix) > 2 {
+			return nil, fmt.Errorf("rrl_prefix takes an IPv4 and an optional IPv6 prefix length")
+		}
+		if rl.ipv4Prefix, err = strconv.Atoi(prefix[0]); err != nil || rl.ipv4Prefix < 0 || rl.ipv4Prefix > 32 {
+			return nil, fmt.Errorf("invalid rrl IPv4 prefix length '%s'", prefix[0])
+		}
+		if len(prefix) > 1 {
+			if rl.ipv6Prefix, err = strconv.Atoi(prefix[1]); err != nil || rl.ipv6Prefix < 0 || rl.ipv6Prefix > 128 {
+				return nil, fmt.Errorf("invalid rrl IPv6 prefix length '%s'", prefix[1])
+			}
+		}
+	}
+
+	if slip, ok := args["rrl_slip"]; ok {
+		if len(slip) != 1 {
+			return nil, fmt.Errorf("rrl_slip takes a single ratio")
+		}
+		if rl.slip, err = strconv.Atoi(slip[0]); err != nil || rl.slip < 0 {
+			return nil, fmt.Errorf("invalid rrl_slip '%s', must not be negative", slip[0])
+		}
+	}
+
+	if window, ok := args["rrl_window"]; ok {
+		if len(window) != 1 {
+			return nil, fmt.Errorf("rrl_window takes a single number of seconds")
+		}
+		if rl.window, err = strconv.Atoi(window[0]); err != nil || rl.window < 1 {
+			return nil, fmt.Errorf("invalid rrl_window '%s', must be positive", window[0])
+		}
+	}
+
+	for _, cidr := range args["rrl_exempt"] {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rrl_exempt CIDR '%s'", cidr)
+		}
+		rl.exempt = append(rl.exempt, n)
+	}
+	return rl, nil
+}
+
+func parseRate(s string) (float64, error) {
+	rate, err := strconv.ParseFloat(s, 64)
+	if err != nil || rate < 0 {
+		return 0, fmt.Errorf("invalid rrl rate '%s', must not be negative", s)
+	}
+	return rate, nil
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route42plugin
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/caddyserver/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+)
+
+func TestSetupOrigins(t *testing.T) {
+	tests := []struct {
+		keys    []string
+		input   string
+		origins []string
+	}{
+		{keys: []string{"example.com:53"}, input: "route42", origins: []string{"example.com."}},
+		// the catch-all root zone is not held back until synced,
+		// so the zones have to be given
+		{keys: []string{".:53"}, input: "route42"},
+		{keys: []string{".:53"}, input: "route42 example.com example.org",
+			origins: []string{"example.com.", "example.org."}},
+	}
+	for _, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		c.ServerBlockKeys = test.keys
+		err := setup(c)
+		if test.origins == nil {
+			if err == nil {
+				t.Errorf("%v %s: expected an error without zones", test.keys, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.input, err)
+		}
+		plugins := dnsserver.GetConfig(c).Plugin
+		p := plugins[len(plugins)-1](nil).(*route42plugin)
+		if !reflect.DeepEqual(p.Origins, test.origins) {
+			t.Errorf("%v %s: got origins %v, want %v", test.keys, test.input, p.Origins, test.origins)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route42plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/coredns/coredns/plugin/file"
+	"github.com/miekg/dns"
+
+	"github.com/thetechnick/route42/coredns/controllers"
+)
+
+const (
+	snapshotExt = ".zone"
+	// snapshotACLFile holds the ACLs of all zones in the snapshot.
+	snapshotACLFile = "allow-query.json"
+)
+
+// snapshot persists the last rendered zones as master files in a directory,
+// so they can be served after a restart until the caches have synced.
+type snapshot struct {
+	dir string
+
+	zones     map[string]*file.Zone
+	zoneNames []string
+	acls      map[string]controllers.ACL
+	sync.RWMutex
+}
+
+var _ zones = (*snapshot)(nil)
+
+func newSnapshot(dir string) *snapshot {
+	return &snapshot{
+		dir:   dir,
+		zones: map[string]*file.Zone{},
+	}
+}
+
+func (s *snapshot) Zones() []string {
+	return s.zoneNames
+}
+
+func (s *snapshot) Zone(zone string) (*file.Zone, bool) {
+	z, ok := s.zones[zone]
+	return z, ok
+}
+
+func (s *snapshot) ACL(zone string) controllers.ACL {
+	return s.acls[zone]
+}
+
+// Load reads all zones of the snapshot.
+// A missing snapshot directory is not an error.
+func (s *snapshot) Load() error {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*"+snapshotExt))
+	if err != nil {
+		return err
+	}
+
+	var zoneNames []string
+	zones := map[string]*file.Zone{}
+	for _, path := range files {
+		zoneName := dns.Fqdn(strings.TrimSuffix(filepath.Base(path), snapshotExt))
+		z, err := loadZoneFile(path, zoneName)
+		if err != nil {
+			return fmt.Errorf("loading snapshot of zone %s: %w", zoneName, err)
+		}
+		zones[zoneName] = z
+		zoneNames = append(zoneNames, zoneName)
+	}
+	acls, err := s.loadACLs()
+	if err != nil {
+		return fmt.Errorf("loading snapshot ACLs: %w", err)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.zones = zones
+	s.zoneNames = zoneNames
+	s.acls = acls
+	return nil
+}
+
+func (s *snapshot) loadACLs() (map[string]controllers.ACL, error) {
+	acls := map[string]controllers.ACL{}
+	b, err := ioutil.ReadFile(filepath.Join(s.dir, snapshotACLFile))
+	if os.IsNotExist(err) {
+		return acls, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cidrs := map[string][]string{}
+	if err := json.Unmarshal(b, &cidrs); err != nil {
+		return nil, err
+	}
+	for zoneName, zoneCIDRs := range cidrs {
+		// keep the valid entries, an ACL must never fail open
+		acl, _ := controllers.ParseACL(zoneCIDRs)
+		if acl == nil {
+			// an empty ACL refuses all clients
+			acl = controllers.ACL{}
+		}
+		acls[zoneName] = acl
+	}
+	return acls, nil
+}
+
+func loadZoneFile(path, zoneName string) (*file.Zone, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return file.Parse(f, zoneName, path, -1)
+}
+
+// Save replaces the snapshot with the given zones.
+// Every file is replaced atomically, so a crash while saving
+// never leaves a partially written zone behind.
+func (s *snapshot) Save(update controllers.Update) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+
+	// ACLs are written first, so a zone is never restored without its ACL
+	cidrs := map[string][]string{}
+	for zoneName, acl := range update.ACLs {
+		cidrs[zoneName] = acl.Strings()
+	}
+	b, err := json.Marshal(cidrs)
+	if err != nil {
+		return err
+	}
+	if err := s.writeFile(snapshotACLFile, func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	}); err != nil {
+		return fmt.Errorf("saving snapshot ACLs: %w", err)
+	}
+
+	keep := map[string]struct{}{}
+	for zoneName, rrs := range update.Records {
+		name := strings.TrimSuffix(zoneName, ".") + snapshotExt
+		keep[name] = struct{}{}
+		if err := s.writeFile(name, func(w io.Writer) error {
+			return controllers.WriteZoneFile(w, zoneName, rrs)
+		}); err != nil {
+			return fmt.Errorf("saving snapshot of zone %s: %w", zoneName, err)
+		}
+	}
+
+	// remove deleted zones
+	files, err := filepath.Glob(filepath.Join(s.dir, "*"+snapshotExt))
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		if _, ok := keep[filepath.Base(path)]; ok {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile atomically replaces the file name in the snapshot directory.
+func (s *snapshot) writeFile(name string, write func(w io.Writer) error) error {
+	f, err := ioutil.TempFile(s.dir, "."+name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(s.dir, name))
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route42plugin
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+
+	"github.com/thetechnick/route42/coredns/controllers"
+)
+
+func mustRRs(t *testing.T, records ...string) []dns.RR {
+	t.Helper()
+	rrs := make([]dns.RR, len(records))
+	for i, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rrs[i] = rr
+	}
+	return rrs
+}
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dir = filepath.Join(dir, "snapshot")
+
+	// a missing snapshot is empty
+	s := newSnapshot(dir)
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Zones()) != 0 {
+		t.Errorf("got zones %v, want none", s.Zones())
+	}
+
+	_, internal, _ := net.ParseCIDR("10.0.0.0/8")
+	update := controllers.Update{
+		Records: map[string][]dns.RR{
+			"example.com.": mustRRs(t,
+				"example.com. 60 IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 3600000 172800",
+				"www.example.com. 300 IN A 192.0.2.1",
+			),
+			"example.org.": mustRRs(t,
+				"example.org. 60 IN SOA ns1.example.org. hostmaster.example.org. 1 86400 7200 3600000 172800",
+			),
+			"example.net.": mustRRs(t,
+				"example.net. 60 IN SOA ns1.example.net. hostmaster.example.net. 1 86400 7200 3600000 172800",
+			),
+		},
+		ACLs: map[string]controllers.ACL{
+			"example.org.": {internal},
+			// refuses all clients
+			"example.net.": {},
+		},
+	}
+	if err := s.Save(update); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := newSnapshot(dir)
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := loaded.Zones(), []string{"example.com.", "example.net.", "example.org."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got zones %v, want %v", got, want)
+	}
+	z, ok := loaded.Zone("example.com.")
+	if !ok {
+		t.Fatal("zone example.com. not loaded")
+	}
+	if elems := z.All(); len(elems) != 1 || elems[0].Name() != "www.example.com." ||
+		len(elems[0].Type(dns.TypeA)) != 1 {
+		t.Error("expected the A record of www.example.com.")
+	}
+
+	acls := []struct {
+		zone, client string
+		allowed      bool
+	}{
+		{zone: "example.com.", client: "192.0.2.10", allowed: true},
+		{zone: "example.org.", client: "10.1.2.3", allowed: true},
+		{zone: "example.org.", client: "192.0.2.10"},
+		{zone: "example.net.", client: "10.1.2.3"},
+	}
+	for _, test := range acls {
+		if allowed := loaded.ACL(test.zone).Allowed(net.ParseIP(test.client)); allowed != test.allowed {
+			t.Errorf("%s from %s: got allowed %v, want %v", test.zone, test.client, allowed, test.allowed)
+		}
+	}
+
+	// deleted zones are removed, no temporary files are left behind
+	delete(update.Records, "example.org.")
+	delete(update.Records, "example.net.")
+	if err := s.Save(update); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(dir, snapshotACLFile),
+		filepath.Join(dir, "example.com"+snapshotExt),
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("got files %v, want %v", files, want)
+	}
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := loaded.Zones(), []string{"example.com."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got zones %v, want %v", got, want)
+	}
+}
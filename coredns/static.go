@@ -0,0 +1,52 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route42plugin
+
+import (
+	"fmt"
+
+	"github.com/coredns/coredns/plugin"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/thetechnick/route42/coredns/controllers"
+)
+
+// NewStatic builds the zones from the objects of the given reader once,
+// with the same reconciler as the agent, and returns a plugin serving them
+// in-process, e.g. from a manifests.Store.
+// The zones are not watched for changes and ALIAS records are not resolved,
+// so neither a cluster nor network access is needed.
+// The returned Update reports the content of the zones and ALIAS errors.
+func NewStatic(c client.Reader) (plugin.Handler, controllers.Update, error) {
+	p, err := newRoute42Plugin(nil)
+	if err != nil {
+		return nil, controllers.Update{}, err
+	}
+	p.Aliases = nil
+
+	var update controllers.Update
+	zoneReconciler := controllers.NewZoneReconciler(
+		c, ctrl.Log.WithName("controllers").WithName("Zone"))
+	zoneReconciler.OnUpdate(func(u controllers.Update) { update = u })
+	zoneReconciler.OnUpdate(p.onUpdate)
+	if _, err := zoneReconciler.Reconcile(ctrl.Request{}); err != nil {
+		return nil, update, fmt.Errorf("building zones: %w", err)
+	}
+	p.zones = zoneReconciler
+	return p, update, nil
+}
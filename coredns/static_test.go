@@ -0,0 +1,70 @@
+/*
+Copyright 2019 The MCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route42plugin
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+
+	"github.com/thetechnick/route42/coredns/manifests"
+)
+
+func TestNewStatic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "route42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(
+		filepath.Join(dir, "manifests.yaml"), []byte(aliasTestManifests), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store := manifests.NewStore(dir)
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	h, update, err := NewStatic(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := update.Records["example.com."]; !ok {
+		t.Errorf("expected records of example.com., got %v", update.Records)
+	}
+	// ALIAS records are not resolved
+	if len(update.AliasErrors) != 2 {
+		t.Errorf("expected both ALIAS records to be reported, got %v", update.AliasErrors)
+	}
+
+	m := &dns.Msg{}
+	m.SetQuestion("www.example.com.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := h.ServeDNS(context.Background(), rec, m); err != nil {
+		t.Fatalf("ServeDNS: %v", err)
+	}
+	if len(rec.Msg.Answer) != 1 ||
+		rec.Msg.Answer[0].String() != "www.example.com.\t60\tIN\tA\t192.0.2.1" {
+		t.Errorf("unexpected answer: %v", rec.Msg.Answer)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	dnsv1alpha1 "github.com/thetechnick/route42/api/v1alpha1"
+	dnsv1alpha2 "github.com/thetechnick/route42/api/v1alpha2"
 	"github.com/thetechnick/route42/controllers"
 )
 
@@ -37,6 +38,7 @@ func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 
 	_ = dnsv1alpha1.AddToScheme(scheme)
+	_ = dnsv1alpha2.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -61,16 +63,31 @@ func main() {
 		os.Exit(1)
 	}
 
+	// agent heartbeats are shared by the Zone and RecordSet controllers
+	agentLeases, err := controllers.NewAgentLeases(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create agent Lease cache")
+		os.Exit(1)
+	}
+	if err = mgr.Add(agentLeases); err != nil {
+		setupLog.Error(err, "unable to add agent Lease cache")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.RecordSetReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("RecordSet"),
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("RecordSet"),
+		Recorder: mgr.GetEventRecorderFor("route42-manager"),
+		Leases:   agentLeases,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "RecordSet")
 		os.Exit(1)
 	}
 	if err = (&controllers.ZoneReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Zone"),
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("Zone"),
+		Recorder: mgr.GetEventRecorderFor("route42-manager"),
+		Leases:   agentLeases,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Zone")
 		os.Exit(1)
@@ -78,12 +95,22 @@ func main() {
 	// +kubebuilder:scaffold:builder
 
 	// Webhooks
+	// The v1alpha1 types are the conversion hub,
+	// setting up their webhooks also serves /convert for v1alpha2.
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		dnsv1alpha1.SetRejectionRecorder(&controllers.WebhookRejections{
+			Recorder: mgr.GetEventRecorderFor("route42-webhook"),
+		})
 		if err = (&dnsv1alpha1.Zone{}).SetupWebhookWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "Zone")
 			os.Exit(1)
 		}
 
+		if err = (&dnsv1alpha1.ClusterZone{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ClusterZone")
+			os.Exit(1)
+		}
+
 		if err = (&dnsv1alpha1.RecordSet{}).SetupWebhookWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "RecordSet")
 			os.Exit(1)